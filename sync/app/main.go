@@ -38,7 +38,7 @@ func main() {
 	log.SetLogDetailsByConfig(c)
 
 	shutdown := make(chan struct{})
-	signals := make(chan os.Signal)
+	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, os.Interrupt, syscall.SIGHUP, syscall.SIGTERM)
 	go func() {
 		for sig := range signals {
@@ -0,0 +1,59 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+var checkpointBucket = []byte("checkpoint")
+
+// boltStore keeps the checkpoints of all chains in an embedded bolt database
+type boltStore struct {
+	db *bolt.DB
+}
+
+func newBoltStore(path string) (*boltStore, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, errors.Wrapf(err, "open checkpoint db %s", path)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(checkpointBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, errors.WithStack(err)
+	}
+	return &boltStore{db: db}, nil
+}
+
+func (s *boltStore) Load(chain string) (*Checkpoint, error) {
+	var cp *Checkpoint
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(checkpointBucket).Get([]byte(chain))
+		if data == nil {
+			return nil
+		}
+		cp = new(Checkpoint)
+		return json.Unmarshal(data, cp)
+	})
+	return cp, errors.WithStack(err)
+}
+
+func (s *boltStore) Save(chain string, cp *Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(checkpointBucket).Put([]byte(chain), data)
+	}))
+}
+
+func (s *boltStore) Close() error {
+	return errors.WithStack(s.db.Close())
+}
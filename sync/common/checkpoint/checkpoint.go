@@ -0,0 +1,55 @@
+package checkpoint
+
+import (
+	"fmt"
+
+	"gitlab.com/sync/common/config"
+)
+
+const (
+	fileStoreType = "file"
+	boltStoreType = "bolt"
+
+	defaultFilePath = "./checkpoint"
+	defaultBoltPath = "./checkpoint.db"
+)
+
+// Checkpoint the last block a consumer has handled
+type Checkpoint struct {
+	Height int    `json:"height"`
+	Hash   string `json:"hash"`
+	// Recent the blocks kept below it for reorg_depth, oldest first, so a reorganization
+	// found after a restart can still be rolled back
+	Recent []Block `json:"recent,omitempty"`
+}
+
+// Block a handled block, by height and hash
+type Block struct {
+	Height int    `json:"height"`
+	Hash   string `json:"hash"`
+}
+
+// Store persists consumer progress per chain
+type Store interface {
+	// Load returns nil if nothing has been saved for the chain yet
+	Load(chain string) (*Checkpoint, error)
+	Save(chain string, cp *Checkpoint) error
+	Close() error
+}
+
+func NewStore(cfg config.Checkpoint) (Store, error) {
+	switch cfg.Type {
+	case "", fileStoreType:
+		if len(cfg.Path) == 0 {
+			cfg.Path = defaultFilePath
+		}
+		return newFileStore(cfg.Path)
+	case boltStoreType:
+		if len(cfg.Path) == 0 {
+			cfg.Path = defaultBoltPath
+		}
+		return newBoltStore(cfg.Path)
+	default:
+		return nil, fmt.Errorf("unsupported checkpoint store %s", cfg.Type)
+	}
+}
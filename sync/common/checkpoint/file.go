@@ -0,0 +1,54 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/sync/common"
+)
+
+// fileStore keeps one json file per chain, replaced atomically on every save
+type fileStore struct {
+	dir string
+}
+
+func newFileStore(dir string) (*fileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &fileStore{dir: dir}, nil
+}
+
+func (s *fileStore) path(chain string) string {
+	return filepath.Join(s.dir, chain+".json")
+}
+
+func (s *fileStore) Load(chain string) (*Checkpoint, error) {
+	data, err := os.ReadFile(s.path(chain))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	cp := new(Checkpoint)
+	if err = json.Unmarshal(data, cp); err != nil {
+		return nil, errors.Wrapf(err, "parse checkpoint %s", s.path(chain))
+	}
+	return cp, nil
+}
+
+func (s *fileStore) Save(chain string, cp *Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return common.WriteFileAtomic(s.path(chain), data, 0644)
+}
+
+func (s *fileStore) Close() error {
+	return nil
+}
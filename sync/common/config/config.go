@@ -8,10 +8,11 @@ import (
 )
 
 type Config struct {
-	App       `toml:"app"`
-	Log       `toml:"log"`
-	Producers map[string]*Producer `toml:"producer"`
-	Consumers map[string]*Consumer `toml:"consumer"`
+	App        `toml:"app"`
+	Log        `toml:"log"`
+	Checkpoint `toml:"checkpoint"`
+	Producers  map[string]*Producer `toml:"producer"`
+	Consumers  map[string]*Consumer `toml:"consumer"`
 }
 
 type App struct {
@@ -38,15 +39,80 @@ type File struct {
 	MaxAge int    `toml:"max_age"`
 }
 
+type Checkpoint struct {
+	Type string `toml:"type"` // file or bolt
+	Path string `toml:"path"` // directory for file, database file for bolt
+}
+
 type Producer struct {
-	URL      string `toml:"url"`
-	Timeout  int    `toml:"timeout"`
-	User     string `toml:"user"`
-	Password string `toml:"password"`
+	Type            string         `toml:"type"` // plugin of the chain, utxo or evm, or a preset such as ltc or optimism, the chain name if empty
+	URL             string         `toml:"url"`
+	Timeout         int            `toml:"timeout"`
+	User            string         `toml:"user"`
+	Password        string         `toml:"password"`
+	Coin            string         `toml:"coin"`             // utxo chains: btc, ltc, doge or bch, the type or chain name if empty
+	Network         string         `toml:"network"`          // utxo chains: mainnet, testnet, signet or regtest
+	Trace           string         `toml:"trace"`            // evm chains: debug or parity to find internal transfers
+	Mode            string         `toml:"mode"`             // evm chains: blocks (default) or logs to follow token transfers only
+	Tokens          []string       `toml:"tokens"`           // evm chains, logs mode: token contracts to follow, all if empty
+	LogRange        int            `toml:"log_range"`        // evm chains, logs mode: max blocks in one eth_getLogs and in one worker loop
+	TokenDecimals   map[string]int `toml:"token_decimals"`   // evm chains: token contract to decimals, to scale amounts
+	ResolveTokens   bool           `toml:"resolve_tokens"`   // evm chains: look up token name, symbol and decimals
+	TokenCache      string         `toml:"token_cache"`      // evm chains: json file keeping resolved tokens
+	ChainID         int64          `toml:"chain_id"`         // evm chains: required, checked against eth_chainId at startup
+	EIP1559         *bool          `toml:"eip1559"`          // evm chains: split fees by the block base fee, on unless false
+	ReceiptsMethod  string         `toml:"receipts_method"`  // evm chains: block or batch, probed if empty
+	OPStack         bool           `toml:"op_stack"`         // evm chains: OP-stack rollup with L1 data fees and deposits
+	SystemTokens    []SystemToken  `toml:"system_tokens"`    // evm chains: contracts logging transfers of the chain coin
+	NotifyURL       string         `toml:"notify_url"`       // new heads pushed by ws:// eth_subscribe (evm) or tcp:// zmqpubhashblock (utxo)
+	Pending         bool           `toml:"pending"`          // follow transactions waiting to be mined
+	PendingInterval int            `toml:"pending_interval"` // milliseconds between mempool polls, empty_interval if 0
+	PendingExpiry   int            `toml:"pending_expiry"`   // milliseconds before a pending transaction is dropped, never if 0 (utxo only)
+	PrefetchWindow  int            `toml:"prefetch_window"`  // how many blocks ahead are fetched while catching up
+	PrefetchWorkers int            `toml:"prefetch_workers"` // how many of them are fetched concurrently
+
+	BatchSize        int `toml:"batch_size"`        // max requests in one json-rpc batch
+	BatchConcurrency int `toml:"batch_concurrency"` // how many batches of a block are requested concurrently
+	BatchRetry       int `toml:"batch_retry"`       // how many times a failed batch is retried
+}
+
+// SystemToken a contract logging transfers of the chain coin, like Polygon MRC20 or Celo GoldToken
+type SystemToken struct {
+	Address string `toml:"address"`
+	Event   string `toml:"event"` // transfer (ERC-20 Transfer) or log_transfer (Polygon LogTransfer)
 }
 
 type Consumer struct {
-	StartHeight int `toml:"start_height"`
+	StartHeight   int           `toml:"start_height"`
+	ReorgDepth    int           `toml:"reorg_depth"` // how many recent blocks are kept to roll back on reorg
+	Confirmations Confirmations `toml:"confirmations"`
+}
+
+// Confirmations how deep a block must be before it is handed to the consumer,
+// either a number of blocks below the chain tip or "finalized"
+type Confirmations struct {
+	Depth     int
+	Finalized bool
+}
+
+const finalizedConfirmations = "finalized"
+
+func (c *Confirmations) UnmarshalTOML(data interface{}) error {
+	switch v := data.(type) {
+	case int64:
+		if v < 0 {
+			return fmt.Errorf("confirmations can not be negative: %d", v)
+		}
+		c.Depth = int(v)
+	case string:
+		if v != finalizedConfirmations {
+			return fmt.Errorf("unsupported confirmations %q, want a number or %q", v, finalizedConfirmations)
+		}
+		c.Finalized = true
+	default:
+		return fmt.Errorf("unsupported confirmations %v", data)
+	}
+	return nil
 }
 
 func NewConfigFromFile(path string) (*Config, error) {
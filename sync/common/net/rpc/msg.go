@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"strings"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
 // DefaultTs ...
@@ -57,3 +60,86 @@ func (err *jsonError) Error() string {
 
 type emptyStruct struct {
 }
+
+// methodNotFoundCode the json-rpc 2.0 code for a method the node does not have
+const methodNotFoundCode = -32601
+
+// notFoundCode the code bitcoind gives a transaction or block it does not have
+const notFoundCode = -5
+
+// executionRevertedCode the code geth and most providers give an eth_call that reverts
+const executionRevertedCode = 3
+
+// vmErrors messages of the EVM failing a call, geth reports those other than a revert with -32000
+var vmErrors = []string{
+	"revert",
+	"invalid opcode",
+	"out of gas",
+	"invalid jump destination",
+	"stack underflow",
+	"stack limit reached",
+	"write protection",
+	"return data out of bounds",
+	"max call depth exceeded",
+	"gas uint64 overflow",
+}
+
+// invalid parameter codes of json-rpc 2.0 and of bitcoind
+const (
+	invalidParamsCode    = -32602
+	invalidParameterCode = -8
+)
+
+// IsMethodNotFound reports whether err is the node refusing an unknown or disabled method.
+// Providers do not agree on the code, so the message is checked as well.
+func IsMethodNotFound(err error) bool {
+	jsonErr, ok := errors.Cause(err).(*jsonError)
+	if !ok {
+		return false
+	}
+	if jsonErr.Code == methodNotFoundCode {
+		return true
+	}
+	msg := strings.ToLower(jsonErr.Message)
+	for _, s := range []string{"method not found", "does not exist", "not supported", "not available", "unsupported method"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsInvalidParams reports whether err is the node refusing the parameters of a method it has,
+// e.g. a getblock verbosity it does not know
+func IsInvalidParams(err error) bool {
+	jsonErr, ok := errors.Cause(err).(*jsonError)
+	if !ok {
+		return false
+	}
+	return jsonErr.Code == invalidParamsCode || jsonErr.Code == invalidParameterCode
+}
+
+// IsNotFound reports whether err is the node not having the transaction or block asked for
+func IsNotFound(err error) bool {
+	jsonErr, ok := errors.Cause(err).(*jsonError)
+	return ok && jsonErr.Code == notFoundCode
+}
+
+// IsExecutionError reports whether err is a call the node executed and that failed in the EVM,
+// e.g. it reverted or ran out of gas, as opposed to the node or the connection failing
+func IsExecutionError(err error) bool {
+	jsonErr, ok := errors.Cause(err).(*jsonError)
+	if !ok {
+		return false
+	}
+	if jsonErr.Code == executionRevertedCode {
+		return true
+	}
+	msg := strings.ToLower(jsonErr.Message)
+	for _, s := range vmErrors {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -39,6 +40,8 @@ type Client struct {
 	Pass           string
 	enableMaxBatch bool
 	maxBatchNum    int
+	batchWorkers   int                                                // how many cut batches are requested concurrently
+	batchRetry     int                                                // how many times a failed batch is retried
 	ResultHandler  func(result []byte, destination interface{}) error // 用以更灵活的支持各式返回结果,目前仅不支持批量请求，需要时请自行修改BatchSyncCall并充分测试
 }
 
@@ -112,6 +115,22 @@ func (c *Client) SetMaxBatchNum(maxBatchNum int) *Client {
 	return c
 }
 
+// SetBatchConcurrency set how many cut batches of one BatchSyncCall are requested concurrently
+func (c *Client) SetBatchConcurrency(workers int) *Client {
+	if workers > 0 {
+		c.batchWorkers = workers
+	}
+	return c
+}
+
+// SetBatchRetry set how many times a failed batch request is retried
+func (c *Client) SetBatchRetry(retry int) *Client {
+	if retry > 0 {
+		c.batchRetry = retry
+	}
+	return c
+}
+
 // SetResultHandler ..
 func (c *Client) SetResultHandler(handler func([]byte, interface{}) error) {
 	c.ResultHandler = handler
@@ -229,41 +248,41 @@ func (c *Client) BatchSyncCall(batch []BatchElem) (err error) {
 		}
 	}
 	batchNum := len(requestList)
-	var buf []byte
 	responseList := make([]*jsonRPCReceiveMessage, 0, totalLength)
 	if !c.enableMaxBatch || c.maxBatchNum <= 0 || batchNum <= c.maxBatchNum {
-		buf, err = c.batchSyncRequest(requestList)
+		responseList, err = c.batchSyncRequestWithRetry(requestList)
 		if err != nil {
 			return
 		}
-		err = json.Unmarshal(buf, &responseList)
-		if err != nil {
-			err = errors.Errorf("can not paste the content into []*jsonRPCReceiveMessage: %s", string(buf))
-			return
-		}
 	} else {
-		for i, j := 0, c.maxBatchNum; true; {
-			if batchNum < j {
-				j = batchNum
-			}
-
-			logrus.Debugf("try batch [%d,%d], total %d", i, j, batchNum)
-			buf, err = c.batchSyncRequest(requestList[i:j])
-			if err != nil {
-				return
-			}
-			tempResMsgs := make([]*jsonRPCReceiveMessage, 0)
-			err = json.Unmarshal(buf, &tempResMsgs)
-			if err != nil {
-				err = errors.Errorf("can not paste the content into []*jsonRPCReceiveMessage: %s", string(buf))
-				return
-			}
-			responseList = append(responseList, tempResMsgs...)
-			i += c.maxBatchNum
-			j += c.maxBatchNum
-			if i >= batchNum {
-				break
+		chunkNum := (batchNum + c.maxBatchNum - 1) / c.maxBatchNum
+		chunkResponses := make([][]*jsonRPCReceiveMessage, chunkNum)
+		chunkErrors := make([]error, chunkNum)
+		workers := c.batchWorkers
+		if workers <= 0 {
+			workers = 1
+		}
+		sem := make(chan struct{}, workers)
+		var wg sync.WaitGroup
+		for k := 0; k < chunkNum; k++ {
+			i, j := k*c.maxBatchNum, min((k+1)*c.maxBatchNum, batchNum)
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(k, i, j int) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				logrus.Debugf("try batch [%d,%d], total %d", i, j, batchNum)
+				chunkResponses[k], chunkErrors[k] = c.batchSyncRequestWithRetry(requestList[i:j])
+			}(k, i, j)
+		}
+		wg.Wait()
+		for k := range chunkResponses {
+			if chunkErrors[k] != nil {
+				return chunkErrors[k]
 			}
+			responseList = append(responseList, chunkResponses[k]...)
 		}
 	}
 
@@ -276,6 +295,7 @@ func handleBatchResult(batch []BatchElem, requestList []*jsonRPCSendMessage, res
 		return err
 	}
 
+	// every element gets its result or error, the first error is returned
 	var elem *BatchElem
 	var req *jsonRPCSendMessage
 	var res *jsonRPCReceiveMessage
@@ -287,24 +307,43 @@ func handleBatchResult(batch []BatchElem, requestList []*jsonRPCSendMessage, res
 		if !ok {
 			return errors.Errorf("can not found result, resuest id %d, method %s, params %v", req.ID, req.Method, req.Params)
 		}
-		if res == nil {
+		switch {
+		case res == nil:
 			elem.Error = errors.New("not found response")
-			return elem.Error
-		}
-		if res.Error != nil {
+		case res.Error != nil:
 			elem.Error = errors.WithStack(res.Error)
-			return elem.Error
-		}
-		if len(res.Result) == 0 {
+		case len(res.Result) == 0:
 			elem.Error = errors.New("not found")
-			return elem.Error
+		default:
+			elem.Error = errors.WithStack(json.Unmarshal(res.Result, elem.Result))
+		}
+		if elem.Error != nil && err == nil {
+			err = elem.Error
 		}
-		elem.Error = errors.WithStack(json.Unmarshal(res.Result, elem.Result))
-		if elem.Error != nil {
-			return elem.Error
+	}
+	return err
+}
+
+func (c *Client) batchSyncRequestWithRetry(msg []*jsonRPCSendMessage) (responseList []*jsonRPCReceiveMessage, err error) {
+	for attempt := 0; attempt <= c.batchRetry; attempt++ {
+		if attempt > 0 {
+			logrus.Warnf("retry batch of %d requests (%d/%d): %v", len(msg), attempt, c.batchRetry, err)
+			time.Sleep(time.Duration(attempt) * 500 * time.Millisecond)
+		}
+		var buf []byte
+		buf, err = c.batchSyncRequest(msg)
+		if err != nil {
+			continue
+		}
+		responseList = make([]*jsonRPCReceiveMessage, 0, len(msg))
+		err = json.Unmarshal(buf, &responseList)
+		if err != nil {
+			err = errors.Errorf("can not paste the content into []*jsonRPCReceiveMessage: %s", string(buf))
+			continue
 		}
+		return responseList, nil
 	}
-	return nil
+	return nil, err
 }
 
 func (c *Client) batchSyncRequest(msg []*jsonRPCSendMessage) (buf []byte, err error) {
@@ -0,0 +1,65 @@
+package rpc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestHandleBatchResult(t *testing.T) {
+	var first, second, third string
+	batch := []BatchElem{
+		{Method: "eth_call", Result: &first},
+		{Method: "eth_call", Result: &second},
+		{Method: "eth_call", Result: &third},
+	}
+	requests := []*jsonRPCSendMessage{{ID: 1}, {ID: 2}, {ID: 3}}
+	// answered out of order, the second element fails
+	responses := []*jsonRPCReceiveMessage{
+		{ID: json.Number("3"), Result: json.RawMessage(`"0x03"`)},
+		{ID: json.Number("2"), Error: &jsonError{Code: executionRevertedCode, Message: "execution reverted"}},
+		{ID: json.Number("1"), Result: json.RawMessage(`"0x01"`)},
+	}
+
+	err := handleBatchResult(batch, requests, responses)
+	if err == nil || err != batch[1].Error {
+		t.Fatalf("got error %v, want the error of the second element", err)
+	}
+	if !IsExecutionError(err) {
+		t.Errorf("%v is not a revert", err)
+	}
+	if batch[0].Error != nil || first != "0x01" {
+		t.Errorf("first element: got %q, %v", first, batch[0].Error)
+	}
+	// elements after a failed one still get their result
+	if batch[2].Error != nil || third != "0x03" {
+		t.Errorf("third element: got %q, %v", third, batch[2].Error)
+	}
+}
+
+func TestHandleBatchResultMissingResponse(t *testing.T) {
+	var result string
+	batch := []BatchElem{{Method: "eth_call", Result: &result}}
+	requests := []*jsonRPCSendMessage{{ID: 1}}
+	if err := handleBatchResult(batch, requests, nil); err == nil {
+		t.Fatal("got no error for a request without response")
+	}
+}
+
+func TestIsExecutionError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&jsonError{Code: executionRevertedCode, Message: "execution reverted"}, true},
+		{&jsonError{Code: -32000, Message: "out of gas"}, true},
+		{&jsonError{Code: -32000, Message: "invalid jump destination"}, true},
+		{&jsonError{Code: -32000, Message: "stack underflow (0 <=> 1)"}, true},
+		{&jsonError{Code: -32005, Message: "limit exceeded"}, false},
+		{&jsonError{Code: -32000, Message: "header not found"}, false},
+	}
+	for _, tt := range tests {
+		if got := IsExecutionError(tt.err); got != tt.want {
+			t.Errorf("%v: got %t, want %t", tt.err, got, tt.want)
+		}
+	}
+}
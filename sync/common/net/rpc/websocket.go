@@ -0,0 +1,154 @@
+package rpc
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+const (
+	wsPingInterval = 30 * time.Second
+	wsReadTimeout  = 2 * wsPingInterval // a connection that stops answering pings is dropped
+	wsWriteTimeout = 10 * time.Second
+)
+
+// WSClient a json-rpc 2.0 client over a websocket, for subscriptions
+type WSClient struct {
+	conn      *websocket.Conn
+	writeLock sync.Mutex
+	idCounter uint64
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+type jsonSubscriptionMessage struct {
+	Method string `json:"method"`
+	Params struct {
+		Subscription string          `json:"subscription"`
+		Result       json.RawMessage `json:"result"`
+	} `json:"params"`
+}
+
+// DialWebSocket connects to a ws:// or wss:// json-rpc endpoint
+func DialWebSocket(url string, timeout time.Duration) (*WSClient, error) {
+	dialer := *websocket.DefaultDialer
+	if timeout > 0 {
+		dialer.HandshakeTimeout = timeout
+	}
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "dial %s", url)
+	}
+	c := &WSClient{
+		conn:   conn,
+		closed: make(chan struct{}),
+	}
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
+	})
+	go c.ping()
+	return c, nil
+}
+
+// Subscribe calls <namespace>_subscribe with params, e.g. eth and "newHeads". The result of
+// every notification is sent to the returned channel, which is closed once the connection
+// drops. A client carries one subscription.
+func (c *WSClient) Subscribe(namespace string, params ...interface{}) (<-chan json.RawMessage, error) {
+	c.idCounter++
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	msg := &jsonRPCSendMessage{Version: string(JSONRPCVersion2), ID: c.idCounter, Method: namespace + "_subscribe", Params: raw}
+	if err := c.write(msg); err != nil {
+		return nil, err
+	}
+
+	var subscription string
+	for len(subscription) == 0 {
+		res := new(jsonRPCReceiveMessage)
+		if err := c.read(res); err != nil {
+			return nil, err
+		}
+		if res.ID.String() != strconv.FormatUint(c.idCounter, 10) {
+			continue
+		}
+		if res.Error != nil {
+			return nil, errors.WithStack(res.Error)
+		}
+		if err := json.Unmarshal(res.Result, &subscription); err != nil {
+			return nil, errors.Wrapf(err, "unmarshaling subscription id: %s", string(res.Result))
+		}
+	}
+
+	result := make(chan json.RawMessage, 16)
+	go func() {
+		defer close(result)
+		defer c.Close()
+		for {
+			notification := new(jsonSubscriptionMessage)
+			if err := c.read(notification); err != nil {
+				return
+			}
+			if notification.Params.Subscription != subscription {
+				continue
+			}
+			select {
+			case result <- notification.Params.Result:
+			case <-c.closed:
+				return
+			}
+		}
+	}()
+	return result, nil
+}
+
+// Close closes the connection, ending the subscription
+func (c *WSClient) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		err = errors.WithStack(c.conn.Close())
+	})
+	return err
+}
+
+func (c *WSClient) read(v interface{}) error {
+	if err := c.conn.SetReadDeadline(time.Now().Add(wsReadTimeout)); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(c.conn.ReadJSON(v))
+}
+
+func (c *WSClient) write(v interface{}) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if err := c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout)); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(c.conn.WriteJSON(v))
+}
+
+// ping keeps the connection alive and lets the read deadline catch a dead node
+func (c *WSClient) ping() {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.closed:
+			return
+		case <-ticker.C:
+			c.writeLock.Lock()
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+			c.writeLock.Unlock()
+			if err != nil {
+				c.Close()
+				return
+			}
+		}
+	}
+}
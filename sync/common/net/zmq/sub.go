@@ -0,0 +1,171 @@
+package zmq
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ZMTP 3.0 frame flags, see https://rfc.zeromq.org/spec/23/
+const (
+	flagMore    byte = 0x01
+	flagLong    byte = 0x02
+	flagCommand byte = 0x04
+
+	greetingSize = 64
+	maxFrameSize = 16 << 20
+)
+
+// Subscriber a ZeroMQ SUB socket speaking ZMTP 3.0 with the NULL mechanism, which is all it
+// takes to follow what bitcoind publishes with -zmqpubhashblock and friends
+type Subscriber struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// Subscribe connects to a PUB socket at address, e.g. tcp://127.0.0.1:28332, and subscribes
+// to topics
+func Subscribe(address string, timeout time.Duration, topics ...string) (*Subscriber, error) {
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	conn, err := dialer.Dial("tcp", strings.TrimPrefix(address, "tcp://"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "dial %s", address)
+	}
+	s := &Subscriber{conn: conn, reader: bufio.NewReader(conn)}
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+	if err := s.handshake(); err != nil {
+		conn.Close()
+		return nil, errors.Wrapf(err, "handshake with %s", address)
+	}
+	for _, topic := range topics {
+		// a ZMTP 3.0 subscription is a message of 0x01 followed by the topic
+		if err := s.writeFrame(0, append([]byte{0x01}, topic...)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	conn.SetDeadline(time.Time{})
+	return s, nil
+}
+
+// Receive blocks until the next message, returned as its frames
+func (s *Subscriber) Receive() ([][]byte, error) {
+	var frames [][]byte
+	for {
+		flags, body, err := s.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		if flags&flagCommand != 0 {
+			continue
+		}
+		frames = append(frames, body)
+		if flags&flagMore == 0 {
+			return frames, nil
+		}
+	}
+}
+
+// Close closes the connection, a blocked Receive returns an error
+func (s *Subscriber) Close() error {
+	return errors.WithStack(s.conn.Close())
+}
+
+// handshake exchanges greetings and READY commands
+func (s *Subscriber) handshake() error {
+	greeting := make([]byte, greetingSize)
+	greeting[0], greeting[9] = 0xff, 0x7f // signature
+	greeting[10], greeting[11] = 3, 0     // version
+	copy(greeting[12:32], "NULL")         // mechanism, as-server and filler stay zero
+	if _, err := s.conn.Write(greeting); err != nil {
+		return errors.WithStack(err)
+	}
+	peer := make([]byte, greetingSize)
+	if _, err := io.ReadFull(s.reader, peer); err != nil {
+		return errors.WithStack(err)
+	}
+	if peer[0] != 0xff || peer[9] != 0x7f || peer[10] < 3 {
+		return errors.New("peer does not speak ZMTP 3")
+	}
+	if mechanism := strings.TrimRight(string(peer[12:32]), "\x00"); mechanism != "NULL" {
+		return errors.Errorf("unsupported security mechanism %s", mechanism)
+	}
+
+	if err := s.writeFrame(flagCommand, readyCommand("SUB")); err != nil {
+		return err
+	}
+	flags, body, err := s.readFrame()
+	if err != nil {
+		return err
+	}
+	if flags&flagCommand == 0 || len(body) == 0 || int(body[0])+1 > len(body) {
+		return errors.New("peer did not send a command")
+	}
+	switch name := string(body[1 : 1+body[0]]); name {
+	case "READY":
+		return nil
+	case "ERROR":
+		return errors.Errorf("peer refused: %s", string(body[1+body[0]:]))
+	default:
+		return errors.Errorf("unexpected command %s", name)
+	}
+}
+
+// readyCommand the READY command body announcing socketType
+func readyCommand(socketType string) []byte {
+	body := []byte{5}
+	body = append(body, "READY"...)
+	body = append(body, byte(len("Socket-Type")))
+	body = append(body, "Socket-Type"...)
+	body = binary.BigEndian.AppendUint32(body, uint32(len(socketType)))
+	return append(body, socketType...)
+}
+
+func (s *Subscriber) writeFrame(flags byte, body []byte) error {
+	frame := make([]byte, 0, 9+len(body))
+	if len(body) > 255 {
+		frame = append(frame, flags|flagLong)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(body)))
+	} else {
+		frame = append(frame, flags, byte(len(body)))
+	}
+	frame = append(frame, body...)
+	_, err := s.conn.Write(frame)
+	return errors.WithStack(err)
+}
+
+func (s *Subscriber) readFrame() (byte, []byte, error) {
+	flags, err := s.reader.ReadByte()
+	if err != nil {
+		return 0, nil, errors.WithStack(err)
+	}
+	var size uint64
+	if flags&flagLong != 0 {
+		var buf [8]byte
+		if _, err := io.ReadFull(s.reader, buf[:]); err != nil {
+			return 0, nil, errors.WithStack(err)
+		}
+		size = binary.BigEndian.Uint64(buf[:])
+	} else {
+		b, err := s.reader.ReadByte()
+		if err != nil {
+			return 0, nil, errors.WithStack(err)
+		}
+		size = uint64(b)
+	}
+	if size > maxFrameSize {
+		return 0, nil, errors.Errorf("frame of %d bytes is too large", size)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(s.reader, body); err != nil {
+		return 0, nil, errors.WithStack(err)
+	}
+	return flags, body, nil
+}
@@ -0,0 +1,189 @@
+package script
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/pkg/errors"
+
+	"gitlab.com/sync/common"
+)
+
+// Class the standard type of a scriptPubKey, named as bitcoind does
+type Class string
+
+const (
+	NonStandard         Class = "nonstandard"
+	PubKey              Class = "pubkey"
+	PubKeyHash          Class = "pubkeyhash"
+	ScriptHash          Class = "scripthash"
+	MultiSig            Class = "multisig"
+	NullData            Class = "nulldata"
+	WitnessV0KeyHash    Class = "witness_v0_keyhash"
+	WitnessV0ScriptHash Class = "witness_v0_scripthash"
+	WitnessV1Taproot    Class = "witness_v1_taproot"
+	WitnessUnknown      Class = "witness_unknown"
+)
+
+// AddressFromScriptPubKey returns the address a hex encoded scriptPubKey pays to, empty for
+// scripts without one. A bare multisig has no address of its own: the P2PKH addresses of its
+// keys are returned in script order joined by commas, e.g. "1Bg...,1cM...", which is not an
+// address that can be paid to.
+func AddressFromScriptPubKey(pkScriptHex string, net *Network) (string, error) {
+	pkScript, err := hex.DecodeString(pkScriptHex)
+	if err != nil {
+		return "", errors.Wrapf(err, "decode script %s", pkScriptHex)
+	}
+	_, addresses, err := ExtractAddresses(pkScript, net)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(addresses, ","), nil
+}
+
+// ExtractAddresses returns the class of pkScript and the addresses it pays to. Pay to pubkey
+// and bare multisig scripts pay to keys, which are reported as their P2PKH addresses.
+func ExtractAddresses(pkScript []byte, net *Network) (Class, []string, error) {
+	switch {
+	case len(pkScript) == 25 && pkScript[0] == txscript.OP_DUP && pkScript[1] == txscript.OP_HASH160 &&
+		pkScript[2] == txscript.OP_DATA_20 && pkScript[23] == txscript.OP_EQUALVERIFY && pkScript[24] == txscript.OP_CHECKSIG:
+		return PubKeyHash, []string{net.pubKeyHashAddress(pkScript[3:23])}, nil
+
+	case len(pkScript) == 23 && pkScript[0] == txscript.OP_HASH160 && pkScript[1] == txscript.OP_DATA_20 &&
+		pkScript[22] == txscript.OP_EQUAL:
+		return ScriptHash, []string{net.scriptHashAddress(pkScript[2:22])}, nil
+
+	case len(pkScript) > 0 && pkScript[0] == txscript.OP_RETURN:
+		return NullData, nil, nil
+	}
+
+	// on chains without segwit a witness program is an anyone-can-spend script
+	if version, program, ok := witnessProgram(pkScript); ok && len(net.Bech32HRP) > 0 {
+		address, err := net.witnessAddress(version, program)
+		if err != nil {
+			return NonStandard, nil, err
+		}
+		switch {
+		case version == 0 && len(program) == 20:
+			return WitnessV0KeyHash, []string{address}, nil
+		case version == 0 && len(program) == 32:
+			return WitnessV0ScriptHash, []string{address}, nil
+		case version == 1 && len(program) == 32:
+			return WitnessV1Taproot, []string{address}, nil
+		default:
+			return WitnessUnknown, []string{address}, nil
+		}
+	}
+
+	if len(pkScript) > 1 && pkScript[len(pkScript)-1] == txscript.OP_CHECKSIG {
+		if key := pkScript[1 : len(pkScript)-1]; int(pkScript[0]) == len(key) && isPubKey(key) {
+			return PubKey, []string{net.pubKeyHashAddress(common.Hash160(key))}, nil
+		}
+	}
+
+	if keys, ok := multiSigKeys(pkScript); ok {
+		addresses := make([]string, 0, len(keys))
+		for _, key := range keys {
+			addresses = append(addresses, net.pubKeyHashAddress(common.Hash160(key)))
+		}
+		return MultiSig, addresses, nil
+	}
+	return NonStandard, nil, nil
+}
+
+// witnessProgram splits a segwit scriptPubKey: a version opcode followed by a 2 to 40 byte push
+func witnessProgram(pkScript []byte) (byte, []byte, bool) {
+	if len(pkScript) < 4 || len(pkScript) > 42 || int(pkScript[1]) != len(pkScript)-2 {
+		return 0, nil, false
+	}
+	switch {
+	case pkScript[0] == txscript.OP_0:
+		return 0, pkScript[2:], true
+	case pkScript[0] >= txscript.OP_1 && pkScript[0] <= txscript.OP_16:
+		return pkScript[0] - txscript.OP_1 + 1, pkScript[2:], true
+	}
+	return 0, nil, false
+}
+
+// multiSigKeys returns the keys of an m-of-n OP_CHECKMULTISIG script
+func multiSigKeys(pkScript []byte) ([][]byte, bool) {
+	if len(pkScript) < 3 || pkScript[len(pkScript)-1] != txscript.OP_CHECKMULTISIG {
+		return nil, false
+	}
+	required, total := pkScript[0], pkScript[len(pkScript)-2]
+	if required < txscript.OP_1 || required > txscript.OP_16 || total < required || total > txscript.OP_16 {
+		return nil, false
+	}
+	pushes, ok := pushedData(pkScript[1 : len(pkScript)-2])
+	if !ok || len(pushes) != int(total-txscript.OP_1+1) {
+		return nil, false
+	}
+	for _, key := range pushes {
+		if !isPubKey(key) {
+			return nil, false
+		}
+	}
+	return pushes, true
+}
+
+// pushedData returns the data pushed by a push only script
+func pushedData(script []byte) ([][]byte, bool) {
+	var result [][]byte
+	tokenizer := txscript.MakeScriptTokenizer(0, script)
+	for tokenizer.Next() {
+		if tokenizer.Opcode() > txscript.OP_16 {
+			return nil, false
+		}
+		result = append(result, tokenizer.Data())
+	}
+	return result, tokenizer.Err() == nil
+}
+
+func isPubKey(key []byte) bool {
+	return isCompressedPubKey(key) || (len(key) == 65 && key[0] == 0x04)
+}
+
+func isCompressedPubKey(key []byte) bool {
+	return len(key) == 33 && (key[0] == 0x02 || key[0] == 0x03)
+}
+
+func (n *Network) pubKeyHashAddress(hash []byte) string {
+	if len(n.CashAddr) > 0 {
+		return encodeCashAddr(n.CashAddr, cashAddrPubKeyHash, hash)
+	}
+	return common.CheckEncode(hash, []byte{n.PubKeyHash})
+}
+
+func (n *Network) scriptHashAddress(hash []byte) string {
+	if len(n.CashAddr) > 0 {
+		return encodeCashAddr(n.CashAddr, cashAddrScriptHash, hash)
+	}
+	return common.CheckEncode(hash, []byte{n.ScriptHash})
+}
+
+// witnessAddress encodes a witness program with bech32 for version 0 and bech32m above (BIP 350)
+func (n *Network) witnessAddress(version byte, program []byte) (string, error) {
+	if len(n.Bech32HRP) == 0 {
+		return "", errors.Errorf("network %s does not support segwit", n.Name)
+	}
+	data, err := bech32.ConvertBits(program, 8, 5, true)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	data = append([]byte{version}, data...)
+	var address string
+	if version == 0 {
+		address, err = bech32.Encode(n.Bech32HRP, data)
+	} else {
+		address, err = bech32.EncodeM(n.Bech32HRP, data)
+	}
+	return address, errors.WithStack(err)
+}
+
+func isWitnessProgram(script []byte) bool {
+	_, _, ok := witnessProgram(script)
+	return ok && bytes.HasPrefix(script, []byte{txscript.OP_0})
+}
@@ -0,0 +1,67 @@
+package script
+
+import (
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil/bech32"
+)
+
+const cashAddrCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// CashAddr type bits of the version byte, the size bits are 0 for a 160 bit hash
+const (
+	cashAddrPubKeyHash byte = 0 << 3
+	cashAddrScriptHash byte = 1 << 3
+)
+
+// encodeCashAddr encodes a 20 byte hash as prefix:payload, see
+// https://github.com/bitcoincashorg/bitcoincash.org/blob/master/spec/cashaddr.md
+func encodeCashAddr(prefix string, version byte, hash []byte) string {
+	payload, _ := bech32.ConvertBits(append([]byte{version}, hash...), 8, 5, true)
+
+	values := make([]byte, 0, len(prefix)+1+len(payload)+8)
+	for i := 0; i < len(prefix); i++ {
+		values = append(values, prefix[i]&0x1f)
+	}
+	values = append(values, 0)
+	values = append(values, payload...)
+	values = append(values, make([]byte, 8)...)
+	checksum := cashAddrPolyMod(values)
+
+	var sb strings.Builder
+	sb.Grow(len(prefix) + 1 + len(payload) + 8)
+	sb.WriteString(prefix)
+	sb.WriteByte(':')
+	for _, v := range payload {
+		sb.WriteByte(cashAddrCharset[v])
+	}
+	for i := 0; i < 8; i++ {
+		sb.WriteByte(cashAddrCharset[(checksum>>(5*(7-i)))&0x1f])
+	}
+	return sb.String()
+}
+
+// cashAddrPolyMod the 40 bit BCH checksum of the CashAddr spec
+func cashAddrPolyMod(values []byte) uint64 {
+	c := uint64(1)
+	for _, d := range values {
+		c0 := byte(c >> 35)
+		c = ((c & 0x07ffffffff) << 5) ^ uint64(d)
+		if c0&0x01 != 0 {
+			c ^= 0x98f2bc8e61
+		}
+		if c0&0x02 != 0 {
+			c ^= 0x79b76d99e2
+		}
+		if c0&0x04 != 0 {
+			c ^= 0xf33e5fb3c4
+		}
+		if c0&0x08 != 0 {
+			c ^= 0xae2eabe2a8
+		}
+		if c0&0x10 != 0 {
+			c ^= 0x1e4f43e470
+		}
+	}
+	return c ^ 1
+}
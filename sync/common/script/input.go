@@ -0,0 +1,91 @@
+package script
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/pkg/errors"
+
+	"gitlab.com/sync/common"
+)
+
+// ErrNoAddress the input does not reveal the address it spends from, e.g. a taproot key
+// path spend or a pay to pubkey spend. The previous output has to be looked up instead.
+var ErrNoAddress = errors.New("input does not reveal its address")
+
+// AddressFromInput derives the address an input spends from its hex encoded scriptSig and
+// witness, for when the previous output is not available
+func AddressFromInput(scriptSigHex string, witnessHex []string, net *Network) (string, error) {
+	scriptSig, err := hex.DecodeString(scriptSigHex)
+	if err != nil {
+		return "", errors.Wrapf(err, "decode scriptSig %s", scriptSigHex)
+	}
+	witness := make([][]byte, 0, len(witnessHex))
+	for _, item := range witnessHex {
+		data, err := hex.DecodeString(item)
+		if err != nil {
+			return "", errors.Wrapf(err, "decode witness %s", item)
+		}
+		witness = append(witness, data)
+	}
+	pushes, ok := pushedData(scriptSig)
+	if !ok {
+		return "", ErrNoAddress
+	}
+
+	if len(witness) > 0 {
+		switch {
+		// P2SH-P2WPKH and P2SH-P2WSH push the witness program as redeem script
+		case len(pushes) == 1 && isWitnessProgram(pushes[0]):
+			return net.scriptHashAddress(common.Hash160(pushes[0])), nil
+		case len(pushes) > 0:
+			return "", ErrNoAddress
+		case len(witness) == 2 && isCompressedPubKey(witness[1]):
+			return net.witnessAddress(0, common.Hash160(witness[1]))
+		}
+		if outputKey, ok := taprootOutputKey(witness); ok {
+			return net.witnessAddress(1, outputKey)
+		}
+		if len(witness) == 1 && (len(witness[0]) == 64 || len(witness[0]) == 65) {
+			// taproot key path spend, only a signature
+			return "", ErrNoAddress
+		}
+		witnessScript := sha256.Sum256(witness[len(witness)-1])
+		return net.witnessAddress(0, witnessScript[:])
+	}
+
+	if len(pushes) < 2 {
+		return "", ErrNoAddress
+	}
+	last := pushes[len(pushes)-1]
+	if len(pushes) == 2 && isPubKey(last) {
+		return net.pubKeyHashAddress(common.Hash160(last)), nil
+	}
+	// the last push of a P2SH spend is the redeem script
+	return net.scriptHashAddress(common.Hash160(last)), nil
+}
+
+// taprootOutputKey recomputes the output key of a taproot script path spend from the revealed
+// script and its control block (BIP 341)
+func taprootOutputKey(witness [][]byte) ([]byte, bool) {
+	// an annex is the last item and starts with 0x50
+	if len(witness) >= 2 && len(witness[len(witness)-1]) > 0 && witness[len(witness)-1][0] == txscript.TaprootAnnexTag {
+		witness = witness[:len(witness)-1]
+	}
+	if len(witness) < 2 {
+		return nil, false
+	}
+	controlBlockBytes := witness[len(witness)-1]
+	if len(controlBlockBytes) == 0 || controlBlockBytes[0]&txscript.TaprootLeafMask != byte(txscript.BaseLeafVersion) {
+		return nil, false
+	}
+	controlBlock, err := txscript.ParseControlBlock(controlBlockBytes)
+	if err != nil {
+		return nil, false
+	}
+	root := controlBlock.RootHash(witness[len(witness)-2])
+	outputKey := txscript.ComputeTaprootOutputKey(controlBlock.InternalKey, root)
+	return schnorr.SerializePubKey(outputKey), true
+}
@@ -0,0 +1,74 @@
+package script
+
+import (
+	"fmt"
+)
+
+// Network the address encoding parameters of a chain
+type Network struct {
+	Name       string
+	PubKeyHash byte   // base58 version byte of P2PKH addresses
+	ScriptHash byte   // base58 version byte of P2SH addresses
+	Bech32HRP  string // human readable part of segwit addresses, empty if segwit is not supported
+	CashAddr   string // CashAddr prefix replacing base58 addresses (bitcoin cash), empty if not used
+}
+
+var (
+	MainNet = &Network{Name: "mainnet", PubKeyHash: 0x00, ScriptHash: 0x05, Bech32HRP: "bc"}
+	TestNet = &Network{Name: "testnet", PubKeyHash: 0x6f, ScriptHash: 0xc4, Bech32HRP: "tb"}
+	SigNet  = &Network{Name: "signet", PubKeyHash: 0x6f, ScriptHash: 0xc4, Bech32HRP: "tb"}
+	RegTest = &Network{Name: "regtest", PubKeyHash: 0x6f, ScriptHash: 0xc4, Bech32HRP: "bcrt"}
+
+	LTCMainNet = &Network{Name: "mainnet", PubKeyHash: 0x30, ScriptHash: 0x32, Bech32HRP: "ltc"}
+	LTCTestNet = &Network{Name: "testnet", PubKeyHash: 0x6f, ScriptHash: 0x3a, Bech32HRP: "tltc"}
+	LTCRegTest = &Network{Name: "regtest", PubKeyHash: 0x6f, ScriptHash: 0x3a, Bech32HRP: "rltc"}
+
+	DOGEMainNet = &Network{Name: "mainnet", PubKeyHash: 0x1e, ScriptHash: 0x16}
+	DOGETestNet = &Network{Name: "testnet", PubKeyHash: 0x71, ScriptHash: 0xc4}
+	DOGERegTest = &Network{Name: "regtest", PubKeyHash: 0x6f, ScriptHash: 0xc4}
+
+	BCHMainNet = &Network{Name: "mainnet", PubKeyHash: 0x00, ScriptHash: 0x05, CashAddr: "bitcoincash"}
+	BCHTestNet = &Network{Name: "testnet", PubKeyHash: 0x6f, ScriptHash: 0xc4, CashAddr: "bchtest"}
+	BCHRegTest = &Network{Name: "regtest", PubKeyHash: 0x6f, ScriptHash: 0xc4, CashAddr: "bchreg"}
+
+	// networks by coin then by name
+	networks = map[string]map[string]*Network{
+		"btc": {
+			MainNet.Name: MainNet,
+			TestNet.Name: TestNet,
+			SigNet.Name:  SigNet,
+			RegTest.Name: RegTest,
+		},
+		"ltc": {
+			LTCMainNet.Name: LTCMainNet,
+			LTCTestNet.Name: LTCTestNet,
+			LTCRegTest.Name: LTCRegTest,
+		},
+		"doge": {
+			DOGEMainNet.Name: DOGEMainNet,
+			DOGETestNet.Name: DOGETestNet,
+			DOGERegTest.Name: DOGERegTest,
+		},
+		"bch": {
+			BCHMainNet.Name: BCHMainNet,
+			BCHTestNet.Name: BCHTestNet,
+			BCHRegTest.Name: BCHRegTest,
+		},
+	}
+)
+
+// GetNetwork returns the network called name of coin (btc, ltc, doge or bch), mainnet if name is empty
+func GetNetwork(coin, name string) (*Network, error) {
+	coinNetworks, ok := networks[coin]
+	if !ok {
+		return nil, fmt.Errorf("unsupported coin %s", coin)
+	}
+	if len(name) == 0 {
+		name = MainNet.Name
+	}
+	net, ok := coinNetworks[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported network %s of %s", name, coin)
+	}
+	return net, nil
+}
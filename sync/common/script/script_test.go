@@ -0,0 +1,132 @@
+package script
+
+import (
+	"testing"
+)
+
+// keys of private keys 1 and 2, hash160 of the first is 751e76e8199196d454941c45d1b3a323f1433bd6
+const (
+	testPubKey1 = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+	testPubKey2 = "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"
+)
+
+func TestAddressFromScriptPubKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		net      *Network
+		pkScript string
+		address  string
+	}{
+		// base58check
+		{"p2pkh mainnet", MainNet, "76a914751e76e8199196d454941c45d1b3a323f1433bd688ac", "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"},
+		{"p2sh mainnet", MainNet, "a914751e76e8199196d454941c45d1b3a323f1433bd687", "3CNHUhP3uyB9EUtRLsmvFUmvGdjGdkTxJw"},
+		{"p2pkh testnet", TestNet, "76a914751e76e8199196d454941c45d1b3a323f1433bd688ac", "mrCDrCybB6J1vRfbwM5hemdJz73FwDBC8r"},
+		{"p2sh testnet", TestNet, "a914751e76e8199196d454941c45d1b3a323f1433bd687", "2N3vVYSK5XRgVSGWy21PnsRmBUywSQNdCsf"},
+		{"p2pk mainnet", MainNet, "21" + testPubKey1 + "ac", "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"},
+		{"p2pkh litecoin", LTCMainNet, "76a914751e76e8199196d454941c45d1b3a323f1433bd688ac", "LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ"},
+		{"p2sh litecoin", LTCMainNet, "a914751e76e8199196d454941c45d1b3a323f1433bd687", "MJaRnao1s62a2zAKSkmG582KbLKianqb7v"},
+		{"p2sh litecoin testnet", LTCTestNet, "a914751e76e8199196d454941c45d1b3a323f1433bd687", "QXHFfTBKYXjaaTH1e7Rox8CcdNPGHVhM59"},
+		{"p2pkh dogecoin", DOGEMainNet, "76a914751e76e8199196d454941c45d1b3a323f1433bd688ac", "DFpN6QqFfUm3gKNaxN6tNcab1FArL9cZLE"},
+		{"p2sh dogecoin", DOGEMainNet, "a914751e76e8199196d454941c45d1b3a323f1433bd687", "A37YDYSwz3438rFtm1SLVcQHyD7JeueC9H"},
+		{"p2pkh dogecoin testnet", DOGETestNet, "76a914751e76e8199196d454941c45d1b3a323f1433bd688ac", "nesRpRaAbTDmZHwmzBkLd2AtF7Z9L9z5S2"},
+
+		// BIP 173
+		{"p2wpkh mainnet", MainNet, "0014751e76e8199196d454941c45d1b3a323f1433bd6", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		{"p2wsh testnet", TestNet, "00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262", "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7"},
+		{"p2wpkh litecoin", LTCMainNet, "0014751e76e8199196d454941c45d1b3a323f1433bd6", "ltc1qw508d6qejxtdg4y5r3zarvary0c5xw7kgmn4n9"},
+
+		// BIP 350
+		{"witness v1 40 bytes", MainNet, "5128751e76e8199196d454941c45d1b3a323f1433bd6751e76e8199196d454941c45d1b3a323f1433bd6", "bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kt5nd6y"},
+		{"witness v16", MainNet, "6002751e", "bc1sw50qgdz25j"},
+		{"p2tr mainnet", MainNet, "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0"},
+		{"p2tr testnet", TestNet, "5120000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433", "tb1pqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesf3hn0c"},
+
+		// CashAddr specification
+		{"cashaddr p2pkh", BCHMainNet, "76a914f5bf48b397dae70be82b3cca4793f8eb2b6cdac988ac", "bitcoincash:qr6m7j9njldwwzlg9v7v53unlr4jkmx6eylep8ekg2"},
+		{"cashaddr p2sh testnet", BCHTestNet, "a914f5bf48b397dae70be82b3cca4793f8eb2b6cdac987", "bchtest:pr6m7j9njldwwzlg9v7v53unlr4jkmx6eyvwc0uz5t"},
+		{"cashaddr p2pkh 2", BCHMainNet, "76a91476a04053bda0a88bda5177b86a15c3b29f55987388ac", "bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a"},
+		{"cashaddr p2sh 2", BCHMainNet, "a91476a04053bda0a88bda5177b86a15c3b29f55987387", "bitcoincash:ppm2qsznhks23z7629mms6s4cwef74vcwvn0h829pq"},
+
+		// no address
+		{"bare multisig", MainNet, "5121" + testPubKey1 + "21" + testPubKey2 + "52ae", "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH,1cMh228HTCiwS8ZsaakH8A8wze1JR5ZsP"},
+		{"null data", MainNet, "6a0568656c6c6f", ""},
+		{"witness program without segwit", BCHMainNet, "0014751e76e8199196d454941c45d1b3a323f1433bd6", ""},
+		{"nonstandard", MainNet, "51", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, err := AddressFromScriptPubKey(tt.pkScript, tt.net)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if address != tt.address {
+				t.Errorf("got %s, want %s", address, tt.address)
+			}
+		})
+	}
+}
+
+func TestAddressFromInput(t *testing.T) {
+	tests := []struct {
+		name      string
+		net       *Network
+		scriptSig string
+		witness   []string
+		address   string
+		err       error
+	}{
+		{
+			name:      "p2pkh",
+			net:       MainNet,
+			scriptSig: "0130" + "21" + testPubKey1,
+			address:   "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH",
+		},
+		{
+			name:    "p2wpkh",
+			net:     MainNet,
+			witness: []string{"30", testPubKey1},
+			address: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4",
+		},
+		{
+			name:      "p2sh-p2wpkh",
+			net:       MainNet,
+			scriptSig: "160014751e76e8199196d454941c45d1b3a323f1433bd6",
+			witness:   []string{"30", testPubKey1},
+			address:   "3JvL6Ymt8MVWiCNHC7oWU6nLeHNJKLZGLN",
+		},
+		{
+			name: "p2tr script path",
+			net:  MainNet,
+			// signature, tapscript <key 1> OP_CHECKSIG, control block of that single leaf
+			witness: []string{
+				"00",
+				"2079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798ac",
+				"c179be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+			},
+			address: "bc1p95hhk2nsp2e8ph23tmm6qte9ck35gpnxg872c437rdf7ef9y2mfst4tcf6",
+		},
+		{
+			name:    "p2tr key path",
+			net:     MainNet,
+			witness: []string{"0000000000000000000000000000000000000000000000000000000000000000" + "0000000000000000000000000000000000000000000000000000000000000000"},
+			err:     ErrNoAddress,
+		},
+		{
+			name:      "p2pkh cashaddr",
+			net:       BCHMainNet,
+			scriptSig: "0130" + "21" + testPubKey1,
+			address:   "bitcoincash:qp63uahgrxged4z5jswyt5dn5v3lzsem6cy4spdc2h",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, err := AddressFromInput(tt.scriptSig, tt.witness, tt.net)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if address != tt.address {
+				t.Errorf("got %s, want %s", address, tt.address)
+			}
+		})
+	}
+}
@@ -2,17 +2,17 @@ package common
 
 import (
 	"crypto/sha256"
-	"encoding/hex"
 	"fmt"
 	"hash"
 	"math/big"
+	"os"
+	"path/filepath"
 	"runtime"
 	"runtime/debug"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcutil/base58"
 
 	"github.com/pkg/errors"
@@ -47,6 +47,30 @@ func TimeConsume(start time.Time) {
 		WithField("cost", time.Since(start).String()).Debug(funcName)
 }
 
+// WriteFileAtomic writes data to a temporary file next to path and renames it over path,
+// so readers never see a partially written file
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, perm)
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(tmp, path))
+}
+
 func DecodeHex(input string) (dec uint64, err error) {
 	input = RemoveHexPrefix(input)
 	dec, err = strconv.ParseUint(input, 16, 64)
@@ -68,47 +92,18 @@ func GetHexNumber(content string) (value *big.Int, err error) {
 	return
 }
 
-type NetID struct {
-	P2pkhID []byte
-	P2shID  []byte
-}
-
-func GetAddressFromScriptSig(asm string, isTest bool) (address string, err error) {
-	netID := NetID{P2pkhID: []byte{0}, P2shID: []byte{5}}
-
-	asms := strings.Split(asm, " ")
-
-	if len(asms) == 0 || len(asms[0]) == 0 {
-		err = errors.New("asm can't be null")
-		return
-	}
-
-	var inputID []byte
-	var pubKeyByte []byte
-
-	pubKeyByte, err = hex.DecodeString(asms[len(asms)-1])
-	if err != nil {
-		return
-	}
-
-	if (len(pubKeyByte) == 65 && pubKeyByte[0] == 4) || // 未压缩公钥
-		(len(pubKeyByte) == 33 && (pubKeyByte[0] == 2 || pubKeyByte[0] == 3)) { // 压缩公钥  //p2pkh
-		inputID = netID.P2pkhID
-	} else if pubKeyByte[len(pubKeyByte)-1] == txscript.OP_CHECKSIG { // p2sh
-		inputID = netID.P2shID
+// ParseDecimal converts a decimal string such as 0.00012 into an integer of its smallest unit,
+// e.g. satoshi for 8 decimals. It is exact and fails rather than rounding.
+func ParseDecimal(value string, decimals int) (*big.Int, error) {
+	r, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return nil, errors.Errorf("can not parse %s as decimal", value)
 	}
-
-	hash160 := Hash160(pubKeyByte)
-
-	// Check for a valid script hash length.
-	if len(hash160) != 20 {
-		err = errors.New("scriptHash must be 20 bytes")
-		return
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)))
+	if !r.IsInt() {
+		return nil, errors.Errorf("%s has more than %d decimals", value, decimals)
 	}
-
-	address = CheckEncode(hash160, inputID)
-	//logrus.Infof("addr:%s org input len:%d byte len:%d", address, len(asms[len(asms)-1]), len(pubKeyByte))
-	return
+	return new(big.Int).Set(r.Num()), nil
 }
 
 func CheckEncode(input []byte, version []byte) string {
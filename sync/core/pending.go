@@ -0,0 +1,130 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/sync/features"
+)
+
+// pendingTracker follows the pending transactions handed to the consumer of a chain until the
+// block pipeline sees them mined, or they left the pool and the pipeline passed the chain tip
+// of that moment without seeing them
+type pendingTracker struct {
+	sync.Mutex
+	chain    string
+	producer features.Producer
+	pending  features.PendingProducer
+	consumer features.PendingConsumer
+	expiry   time.Duration
+	txs      map[string]*pendingEntry
+}
+
+type pendingEntry struct {
+	seen   time.Time
+	leftAt int // chain height when the transaction left the pool, 0 while it is in
+}
+
+func newPendingTracker(chain string, producer features.Producer, consumer features.PendingConsumer, expiry time.Duration) *pendingTracker {
+	return &pendingTracker{
+		chain:    chain,
+		producer: producer,
+		pending:  producer.(features.PendingProducer),
+		consumer: consumer,
+		expiry:   expiry,
+		txs:      make(map[string]*pendingEntry),
+	}
+}
+
+// poll hands the transactions that entered the pool to the consumer and notes those that left
+func (t *pendingTracker) poll() error {
+	added, removed, err := t.pending.GetPendingTransactions()
+	if err != nil {
+		return err
+	}
+	var height int
+	if len(removed) > 0 {
+		// a transaction that left the pool is mined at this height at the latest
+		if height, err = t.producer.GetChainHeight(); err != nil {
+			return err
+		}
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	now := time.Now()
+	news := make([]features.Transaction, 0, len(added))
+	for _, tx := range added {
+		if e, ok := t.txs[tx.GetHash()]; ok {
+			// back in the pool, e.g. after a reorganization
+			e.leftAt = 0
+			continue
+		}
+		t.txs[tx.GetHash()] = &pendingEntry{seen: now}
+		news = append(news, tx)
+	}
+	for _, hash := range removed {
+		if e, ok := t.txs[hash]; ok && e.leftAt == 0 {
+			e.leftAt = height
+		}
+	}
+	logrus.
+		WithField("chain", t.chain).
+		WithField("added", len(news)).
+		WithField("removed", len(removed)).
+		WithField("tracked", len(t.txs)).
+		Debug("pending transactions")
+	if len(news) == 0 {
+		return nil
+	}
+	return t.consumer.NewPendingTransactions(news)
+}
+
+// confirm is called by the block pipeline once block is stored with its related txs. Pending
+// transactions in it are confirmed, those it should have included are dropped.
+func (t *pendingTracker) confirm(block features.Block, txs []features.Transaction) {
+	t.Lock()
+	defer t.Unlock()
+	if len(t.txs) == 0 {
+		return
+	}
+	height := block.GetHeight()
+	var hashes []string
+	if b, ok := block.(features.TxHashesBlock); ok {
+		hashes = b.GetTxHashes()
+	} else {
+		for _, tx := range txs {
+			hashes = append(hashes, tx.GetHash())
+		}
+	}
+	var confirmed, dropped []string
+	for _, hash := range hashes {
+		if _, ok := t.txs[hash]; ok {
+			confirmed = append(confirmed, hash)
+			delete(t.txs, hash)
+		}
+	}
+	now := time.Now()
+	for hash, e := range t.txs {
+		if (e.leftAt > 0 && height >= e.leftAt) || (t.expiry > 0 && now.Sub(e.seen) > t.expiry) {
+			dropped = append(dropped, hash)
+			delete(t.txs, hash)
+		}
+	}
+	if len(confirmed) > 0 {
+		if err := t.consumer.ConfirmPending(confirmed, height); err != nil {
+			logrus.
+				WithField("chain", t.chain).
+				Errorf("confirm %d pending transactions on block %d: %v", len(confirmed), height, err)
+		}
+	}
+	if len(dropped) > 0 {
+		if err := t.consumer.DropPending(dropped); err != nil {
+			logrus.
+				WithField("chain", t.chain).
+				Errorf("drop %d pending transactions: %v", len(dropped), err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"fmt"
+
+	"gitlab.com/sync/features"
+)
+
+// fetched a block with its related transactions, or the error met while fetching it
+type fetched struct {
+	block features.Block
+	txs   []features.Transaction
+	err   error
+}
+
+// prefetch fetches blocks from..to with at most workers requests in flight. The returned
+// channels are ordered by height, so each block can be delivered as soon as it and every
+// block below it are ready. Closing done stops starting new requests.
+func prefetch(producer features.Producer, from, to, workers int, done <-chan struct{}) []chan *fetched {
+	if workers <= 0 {
+		workers = 1
+	}
+	results := make([]chan *fetched, to-from+1)
+	for i := range results {
+		results[i] = make(chan *fetched, 1)
+	}
+	go func() {
+		sem := make(chan struct{}, workers)
+		for i := range results {
+			select {
+			case <-done:
+				return
+			case sem <- struct{}{}:
+			}
+			go func(i int) {
+				defer func() { <-sem }()
+				results[i] <- fetchBlock(producer, from+i)
+			}(i)
+		}
+	}()
+	return results
+}
+
+func fetchBlock(producer features.Producer, height int) *fetched {
+	block, err := producer.GetBlockByHeight(height)
+	if err != nil {
+		return &fetched{err: err}
+	}
+	txs, err := producer.GetRelatedTransactions(block)
+	if err != nil {
+		return &fetched{err: err}
+	}
+	return &fetched{block: block, txs: txs}
+}
+
+// fetchRange fetches from..to with a single GetBlocksByRange, delivered the same way as prefetch
+func fetchRange(producer features.Producer, ranged features.RangeProducer, from, to int) []chan *fetched {
+	results := make([]chan *fetched, to-from+1)
+	for i := range results {
+		results[i] = make(chan *fetched, 1)
+	}
+	blocks, err := ranged.GetBlocksByRange(from, to)
+	if err == nil && len(blocks) != len(results) {
+		err = fmt.Errorf("got %d blocks for range %d-%d", len(blocks), from, to)
+	}
+	for i := range results {
+		if err != nil {
+			results[i] <- &fetched{err: err}
+			continue
+		}
+		txs, err := producer.GetRelatedTransactions(blocks[i])
+		if err != nil {
+			results[i] <- &fetched{err: err}
+			continue
+		}
+		results[i] <- &fetched{block: blocks[i], txs: txs}
+	}
+	return results
+}
@@ -1,11 +1,14 @@
 package core
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"gitlab.com/sync/common"
+	"gitlab.com/sync/common/checkpoint"
 	"gitlab.com/sync/common/config"
 	"gitlab.com/sync/features"
 	"gitlab.com/sync/plugins"
@@ -13,19 +16,84 @@ import (
 
 const minDuration time.Duration = -1 << 63
 
+// safeUncheckedDepth confirmations below which blocks without parent hash, whose
+// reorganizations go unnoticed, are likely to be orphaned after they are handed over
+const safeUncheckedDepth = 64
+
 type Processor struct {
 	*config.Config
 	plugins map[string]*plugins.Plugin
+	store   checkpoint.Store
+	pending map[string]*pendingTracker // chains following pending transactions
 }
 
 func NewProcessor(c *config.Config) (features.Processor, error) {
-	if p, err := plugins.Loader(c.App.Chains, c); err != nil {
+	store, err := checkpoint.NewStore(c.Checkpoint)
+	if err != nil {
 		return nil, err
-	} else {
-		return &Processor{
-			Config:  c,
-			plugins: p,
-		}, nil
+	}
+	p, err := plugins.Loader(c.App.Chains, c, store)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	pending := make(map[string]*pendingTracker)
+	for chain, v := range p {
+		if _, ok := v.Producer.(features.RangeProducer); ok {
+			checkUncheckedConfirmations(chain, v.Producer, &c.Consumers[chain].Confirmations)
+		}
+		if c.Consumers[chain].Confirmations.Finalized {
+			if _, ok := v.Producer.(features.FinalizedProducer); !ok {
+				store.Close()
+				return nil, fmt.Errorf("chain %s does not support finalized confirmations", chain)
+			}
+		}
+		if len(c.Producers[chain].NotifyURL) > 0 {
+			if _, ok := v.Producer.(features.HeadNotifier); !ok {
+				store.Close()
+				return nil, fmt.Errorf("chain %s does not support head notifications", chain)
+			}
+		}
+		if c.Producers[chain].Pending {
+			_, ok := v.Producer.(features.PendingProducer)
+			consumer, ok2 := v.Consumer.(features.PendingConsumer)
+			if !ok || !ok2 {
+				store.Close()
+				return nil, fmt.Errorf("chain %s does not support pending transactions", chain)
+			}
+			expiry := time.Millisecond * time.Duration(c.Producers[chain].PendingExpiry)
+			pending[chain] = newPendingTracker(chain, v.Producer, consumer, expiry)
+		}
+	}
+	return &Processor{
+		Config:  c,
+		plugins: p,
+		store:   store,
+		pending: pending,
+	}, nil
+}
+
+// checkUncheckedConfirmations guards a range producer, whose blocks may have no parent hash so
+// that reorganizations are not detected: without confirmations it waits for finalized blocks
+// where the chain has them, and it warns when the confirmations are shallow.
+func checkUncheckedConfirmations(chain string, producer features.Producer, confirmations *config.Confirmations) {
+	if confirmations.Finalized {
+		return
+	}
+	if _, ok := producer.(features.FinalizedProducer); ok && confirmations.Depth == 0 {
+		confirmations.Finalized = true
+		logrus.
+			WithField("chain", chain).
+			Info("reorganizations of range blocks are not detected, confirmations default to finalized")
+		return
+	}
+	if confirmations.Depth < safeUncheckedDepth {
+		logrus.
+			WithField("chain", chain).
+			WithField("confirmations", confirmations.Depth).
+			Warnf("reorganizations of range blocks are not detected, blocks less than %d deep may be "+
+				"handed over and then orphaned: set confirmations to finalized or at least %d",
+				safeUncheckedDepth, safeUncheckedDepth)
 	}
 }
 
@@ -35,14 +103,36 @@ func (p *Processor) Loop(shutdown chan struct{}) {
 		wg.Add(1)
 		go func(chain string, producer features.Producer, consumer features.Consumer) {
 			defer wg.Done()
+			wake := make(chan struct{}, 1)
+			if notifier, ok := producer.(features.HeadNotifier); ok && len(p.Producers[chain].NotifyURL) > 0 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					p.followHeads(chain, notifier, wake, shutdown)
+				}()
+			}
 			timer := time.NewTimer(minDuration)
+			var atTip bool
 			for {
 				select {
 				case <-shutdown:
 					logrus.Infof("%s stop working", chain)
 					return
+				case <-wake:
+					// a new head ends the wait at the tip, not the one after an error
+					if atTip {
+						timer.Reset(minDuration)
+					}
 				case <-timer.C:
 					emptyLoop, err := p.worker(chain, producer, consumer)
+					atTip = err == nil && emptyLoop
+					var tooDeep *reorgTooDeepError
+					if errors.As(err, &tooDeep) {
+						logrus.
+							WithField("chain", chain).
+							Errorf("stop syncing: %v", err)
+						return
+					}
 					if err != nil {
 						logrus.Error(err)
 						timer.Reset(time.Millisecond * time.Duration(p.App.ErrorInterval))
@@ -55,7 +145,71 @@ func (p *Processor) Loop(shutdown chan struct{}) {
 			}
 		}(k, v.Producer, v.Consumer)
 	}
+	for k, v := range p.pending {
+		wg.Add(1)
+		go func(chain string, tracker *pendingTracker) {
+			defer wg.Done()
+			interval := p.Producers[chain].PendingInterval
+			if interval <= 0 {
+				interval = p.App.EmptyInterval
+			}
+			timer := time.NewTimer(minDuration)
+			for {
+				select {
+				case <-shutdown:
+					logrus.Infof("%s stop following pending transactions", chain)
+					return
+				case <-timer.C:
+					if err := tracker.poll(); err != nil {
+						logrus.WithField("chain", chain).Error(err)
+						timer.Reset(time.Millisecond * time.Duration(p.App.ErrorInterval))
+					} else {
+						timer.Reset(time.Millisecond * time.Duration(interval))
+					}
+				}
+			}
+		}(k, v)
+	}
 	wg.Wait()
+	if err := p.store.Close(); err != nil {
+		logrus.Error(err)
+	}
+}
+
+// followHeads keeps a head subscription open and signals wake on every new head. While the
+// subscription is down the worker keeps polling every empty_interval.
+func (p *Processor) followHeads(chain string, notifier features.HeadNotifier, wake chan<- struct{}, shutdown chan struct{}) {
+	for {
+		heads, err := notifier.SubscribeNewHeads(shutdown)
+		if err != nil {
+			logrus.
+				WithField("chain", chain).
+				Warnf("can not subscribe to new heads, keep polling: %v", err)
+		} else {
+			logrus.
+				WithField("chain", chain).
+				Info("subscribed to new heads")
+			for range heads {
+				select {
+				case wake <- struct{}{}:
+				default:
+				}
+			}
+			select {
+			case <-shutdown:
+				return
+			default:
+			}
+			logrus.
+				WithField("chain", chain).
+				Warn("new head subscription dropped, keep polling until it is back")
+		}
+		select {
+		case <-shutdown:
+			return
+		case <-time.After(time.Millisecond * time.Duration(p.App.ErrorInterval)):
+		}
+	}
 }
 
 func (p *Processor) worker(chain string, producer features.Producer, consumer features.Consumer) (bool, error) {
@@ -72,11 +226,11 @@ func (p *Processor) worker(chain string, producer features.Producer, consumer fe
 
 	lastBlockHeight := current.GetHeight()
 	nextBlockHeight := lastBlockHeight + 1
-	maxBlockHeight, err := producer.GetChainHeight()
+	maxBlockHeight, err := p.safeHeight(chain, producer)
 	if err != nil {
 		return false, err
 	}
-	if nextBlockHeight > maxBlockHeight && maxBlockHeight > 0 {
+	if nextBlockHeight > maxBlockHeight {
 		logrus.
 			WithField("chain", chain).
 			WithField("next_block_height", nextBlockHeight).
@@ -84,22 +238,101 @@ func (p *Processor) worker(chain string, producer features.Producer, consumer fe
 			Infof("reach max block height")
 		return true, nil
 	}
-	nextBlock, err := producer.GetBlockByHeight(nextBlockHeight)
-	if err != nil {
-		return false, err
+	ranged, isRanged := producer.(features.RangeProducer)
+	window := p.Producers[chain].PrefetchWindow
+	if isRanged {
+		window = ranged.MaxRange()
 	}
-	txs, err := producer.GetRelatedTransactions(nextBlock)
-	if err != nil {
-		return false, err
+	lastFetchHeight := nextBlockHeight
+	if window > 1 && maxBlockHeight > nextBlockHeight {
+		lastFetchHeight = min(nextBlockHeight+window-1, maxBlockHeight)
 	}
-	if err := consumer.NewBlock(nextBlock, txs); err != nil {
-		return false, err
+
+	done := make(chan struct{})
+	defer close(done)
+	var results []chan *fetched
+	if isRanged {
+		results = fetchRange(producer, ranged, nextBlockHeight, lastFetchHeight)
+	} else {
+		results = prefetch(producer, nextBlockHeight, lastFetchHeight, p.Producers[chain].PrefetchWorkers, done)
+	}
+	for _, result := range results {
+		f := <-result
+		if f.err != nil {
+			return false, f.err
+		}
+		// a block without parent hash can not be checked, e.g. one built from event logs
+		if len(current.GetHash()) > 0 && len(f.block.GetParentHash()) > 0 && f.block.GetParentHash() != current.GetHash() {
+			return false, p.rollback(chain, producer, consumer, current.GetHeight())
+		}
+		if err := consumer.NewBlock(f.block, f.txs); err != nil {
+			return false, err
+		}
+		if tracker, ok := p.pending[chain]; ok {
+			tracker.confirm(f.block, f.txs)
+		}
+		current = f.block
 	}
 
 	logrus.
 		WithField("chain", chain).
-		WithField("block_height", 0).
+		WithField("block_height", current.GetHeight()).
+		WithField("block_count", lastFetchHeight-lastBlockHeight).
 		WithField("cost", time.Since(start).String()).
 		Info("worker complete")
 	return false, nil
 }
+
+// safeHeight returns the highest block with enough confirmations to be handed to the consumer
+func (p *Processor) safeHeight(chain string, producer features.Producer) (int, error) {
+	confirmations := p.Consumers[chain].Confirmations
+	if confirmations.Finalized {
+		return producer.(features.FinalizedProducer).GetFinalizedHeight()
+	}
+	height, err := producer.GetChainHeight()
+	if err != nil {
+		return 0, err
+	}
+	return height - confirmations.Depth, nil
+}
+
+// reorgTooDeepError the chain reorganized below the oldest block the consumer keeps, so the
+// common ancestor can not be found. Retrying does not help, the chain has to be resynced.
+type reorgTooDeepError struct {
+	chain    string
+	height   int // the orphaned block
+	ancestor int // the first height the consumer no longer keeps
+}
+
+func (e *reorgTooDeepError) Error() string {
+	return fmt.Sprintf("chain %s reorganized at %d deeper than the blocks kept by reorg_depth, "+
+		"no common ancestor down to %d: resync it from start_height %d or below after removing its checkpoint",
+		e.chain, e.height, e.ancestor+1, e.ancestor)
+}
+
+// rollback is called when the block stored at height is no longer on the canonical chain.
+// It walks back to the common ancestor and reverts everything the consumer stored above it.
+func (p *Processor) rollback(chain string, producer features.Producer, consumer features.Consumer, height int) error {
+	for ancestor := height - 1; ; ancestor-- {
+		stored, err := consumer.GetBlockInfoByHeight(ancestor)
+		if errors.Is(err, features.ErrBlockNotFound) {
+			return &reorgTooDeepError{chain: chain, height: height, ancestor: ancestor}
+		}
+		if err != nil {
+			return errors.Wrapf(err, "chain %s reorganized at %d, can not find common ancestor at %d", chain, height, ancestor)
+		}
+		canonical, err := producer.GetBlockByHeight(ancestor)
+		if err != nil {
+			return err
+		}
+		if canonical.GetHash() != stored.GetHash() {
+			continue
+		}
+		logrus.
+			WithField("chain", chain).
+			WithField("orphaned_height", height).
+			WithField("common_ancestor", ancestor).
+			Warn("chain reorganization detected")
+		return consumer.Rollback(ancestor)
+	}
+}
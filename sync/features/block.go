@@ -1,5 +1,10 @@
 package features
 
+import "errors"
+
+// ErrBlockNotFound the block is not (or no longer) kept by the consumer
+var ErrBlockNotFound = errors.New("block not found")
+
 type Block interface {
 	GetHash() string
 	GetHeight() int
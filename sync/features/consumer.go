@@ -0,0 +1,135 @@
+package features
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/sync/common/checkpoint"
+)
+
+// BaseConsumer keeps the blocks handed to a consumer and saves its checkpoint. Consumers embed
+// it and only add what they do with the transactions.
+type BaseConsumer struct {
+	mu            sync.Mutex
+	chain         string
+	currentHeight int
+	history       *BlockHistory
+	store         checkpoint.Store
+}
+
+// NewBaseConsumer restores the last checkpoint of chain, startHeight only applies until the
+// first one is saved
+func NewBaseConsumer(chain string, startHeight, reorgDepth int, store checkpoint.Store) (*BaseConsumer, error) {
+	c := &BaseConsumer{
+		chain:         chain,
+		currentHeight: startHeight,
+		history:       NewBlockHistory(reorgDepth),
+		store:         store,
+	}
+	cp, err := store.Load(chain)
+	if err != nil {
+		return nil, err
+	}
+	if cp != nil {
+		c.currentHeight = cp.Height
+		for _, block := range cp.Recent {
+			c.history.Add(&BlockInfo{Height: block.Height, Hash: block.Hash}, nil)
+		}
+		c.history.Add(&BlockInfo{Height: cp.Height, Hash: cp.Hash}, nil)
+	}
+	return c, nil
+}
+
+func (c *BaseConsumer) GetCurrentBlockInfo() (Block, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.history.Get(c.currentHeight); ok {
+		return &e.BlockInfo, nil
+	}
+	return &BlockInfo{
+		Height: c.currentHeight,
+	}, nil
+}
+
+func (c *BaseConsumer) GetBlockInfoByHeight(height int) (Block, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.history.Get(height); ok {
+		return &e.BlockInfo, nil
+	}
+	return nil, ErrBlockNotFound
+}
+
+func (c *BaseConsumer) NewBlock(b Block, txs []Transaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.store.Save(c.chain, c.checkpoint(b)); err != nil {
+		return err
+	}
+	c.currentHeight = b.GetHeight()
+	c.history.Add(b, txs)
+	return nil
+}
+
+func (c *BaseConsumer) Rollback(height int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kept := &BlockInfo{Height: height}
+	if e, ok := c.history.Get(height); ok {
+		kept = &e.BlockInfo
+	}
+	if err := c.store.Save(c.chain, c.checkpoint(kept)); err != nil {
+		return err
+	}
+	for _, e := range c.history.Rollback(height) {
+		for _, tx := range e.Transactions {
+			logrus.
+				WithField("chain", c.chain).
+				WithField("transaction_hash", tx.GetHash()).
+				WithField("transfers", len(tx.Transfers())).
+				WithField("status", tx.Status()).
+				WithField("fee", tx.Fee()).
+				Warnf("revert transaction on block %d", e.Height)
+		}
+		logrus.
+			WithField("chain", c.chain).
+			WithField("block_height", e.Height).
+			WithField("block_hash", e.Hash).
+			Warn("revert block")
+	}
+	c.currentHeight = height
+	return nil
+}
+
+func (c *BaseConsumer) ConfirmPending(hashes []string, height int) error {
+	for _, hash := range hashes {
+		logrus.
+			WithField("chain", c.chain).
+			WithField("transaction_hash", hash).
+			Infof("pending transaction confirmed on block %d", height)
+	}
+	return nil
+}
+
+func (c *BaseConsumer) DropPending(hashes []string) error {
+	for _, hash := range hashes {
+		logrus.
+			WithField("chain", c.chain).
+			WithField("transaction_hash", hash).
+			Warn("pending transaction dropped")
+	}
+	return nil
+}
+
+// checkpoint marks b as the last handled block. The blocks kept below it are saved along, so a
+// reorganization found after a restart can still be rolled back.
+func (c *BaseConsumer) checkpoint(b Block) *checkpoint.Checkpoint {
+	cp := &checkpoint.Checkpoint{Height: b.GetHeight(), Hash: b.GetHash()}
+	for _, info := range c.history.Infos() {
+		if info.Height < b.GetHeight() {
+			cp.Recent = append(cp.Recent, checkpoint.Block{Height: info.Height, Hash: info.Hash})
+		}
+	}
+	return cp
+}
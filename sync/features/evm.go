@@ -0,0 +1,27 @@
+package features
+
+import "math/big"
+
+// EVMFee how the fee of an EVM transaction was paid, amounts in wei
+type EVMFee struct {
+	GasLimit          *big.Int
+	GasUsed           *big.Int
+	EffectiveGasPrice *big.Int
+	// BaseFee per gas of the block, nil before EIP-1559
+	BaseFee *big.Int
+	// Burnt gasUsed * baseFee, destroyed by the protocol. Nil on OP-stack rollups, which pay the
+	// base fee to a fee vault instead.
+	Burnt *big.Int
+	// Tip gasUsed * (effectiveGasPrice - baseFee), paid to the block producer. Nil on OP-stack
+	// rollups along with Burnt.
+	Tip *big.Int
+	// L1Fee data fee of OP-stack rollups, part of the transaction fee, nil elsewhere
+	L1Fee *big.Int
+}
+
+// EVMTransaction is implemented by transactions of EVM chains whose fee is known
+type EVMTransaction interface {
+	Transaction
+	// EVMFee nil when the receipt was not fetched, e.g. when following logs only
+	EVMFee() *EVMFee
+}
@@ -0,0 +1,80 @@
+package features
+
+import "sort"
+
+const defaultHistoryDepth = 64
+
+// HistoryEntry a block kept by BlockHistory together with its transactions
+type HistoryEntry struct {
+	BlockInfo
+	Transactions []Transaction
+}
+
+// BlockHistory keeps the most recent blocks handed to a consumer, so they can be
+// reverted when the chain reorganizes. It is not safe for concurrent use.
+type BlockHistory struct {
+	depth   int
+	highest int
+	entries map[int]*HistoryEntry
+}
+
+func NewBlockHistory(depth int) *BlockHistory {
+	if depth <= 0 {
+		depth = defaultHistoryDepth
+	}
+	return &BlockHistory{
+		depth:   depth,
+		entries: make(map[int]*HistoryEntry, depth),
+	}
+}
+
+// Add records b as the newest block and forgets blocks that fall out of the depth
+func (h *BlockHistory) Add(b Block, txs []Transaction) {
+	h.entries[b.GetHeight()] = &HistoryEntry{
+		BlockInfo: BlockInfo{
+			Hash:       b.GetHash(),
+			Height:     b.GetHeight(),
+			ParentHash: b.GetParentHash(),
+			BlockTime:  b.GetBlockTime(),
+		},
+		Transactions: txs,
+	}
+	h.highest = b.GetHeight()
+	for height := range h.entries {
+		if height <= h.highest-h.depth {
+			delete(h.entries, height)
+		}
+	}
+}
+
+// Infos the kept blocks, oldest first
+func (h *BlockHistory) Infos() []BlockInfo {
+	result := make([]BlockInfo, 0, len(h.entries))
+	for _, e := range h.entries {
+		result = append(result, e.BlockInfo)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Height < result[j].Height
+	})
+	return result
+}
+
+func (h *BlockHistory) Get(height int) (*HistoryEntry, bool) {
+	e, ok := h.entries[height]
+	return e, ok
+}
+
+// Rollback removes every block above height and returns them, newest first
+func (h *BlockHistory) Rollback(height int) []*HistoryEntry {
+	result := make([]*HistoryEntry, 0, h.highest-height)
+	for i := h.highest; i > height; i-- {
+		if e, ok := h.entries[i]; ok {
+			result = append(result, e)
+			delete(h.entries, i)
+		}
+	}
+	if h.highest > height {
+		h.highest = height
+	}
+	return result
+}
@@ -0,0 +1,25 @@
+package features
+
+// PendingProducer is implemented by producers that watch transactions waiting to be mined. Every
+// call returns the transactions that entered the pool since the previous call, with status
+// pending, and the hashes of those that left it, mined or not. Producers that can not tell
+// when a transaction leaves the pool return no hashes, pending_expiry drops them instead.
+type PendingProducer interface {
+	GetPendingTransactions() (added []Transaction, removed []string, err error)
+}
+
+// PendingConsumer is implemented by consumers that show transactions before they are mined
+type PendingConsumer interface {
+	NewPendingTransactions(txs []Transaction) error
+	// ConfirmPending is called once pending transactions are seen in the block at height
+	ConfirmPending(hashes []string, height int) error
+	// DropPending is called once pending transactions left the pool without being mined
+	DropPending(hashes []string) error
+}
+
+// TxHashesBlock is implemented by blocks that know the hashes of all their transactions, so
+// pending transactions without transfers are confirmed as well
+type TxHashesBlock interface {
+	Block
+	GetTxHashes() []string
+}
@@ -1,5 +1,7 @@
 package features
 
+import "math/big"
+
 // Producer Fetch on chain data
 type Producer interface {
 	GetChainHeight() (int, error)
@@ -7,16 +9,52 @@ type Producer interface {
 	GetRelatedTransactions(b Block) ([]Transaction, error)
 }
 
+// FinalizedProducer is implemented by producers of chains with a finality gadget
+type FinalizedProducer interface {
+	GetFinalizedHeight() (int, error)
+}
+
+// HeadNotifier is implemented by producers that can push new chain heads rather than be polled.
+// SubscribeNewHeads returns a channel receiving a value per new head, closed once the
+// subscription drops or done is closed.
+type HeadNotifier interface {
+	SubscribeNewHeads(done <-chan struct{}) (<-chan struct{}, error)
+}
+
+// RangeProducer is implemented by producers that fetch a range of blocks in one go, e.g. from an
+// event index. It returns one block per height in height order, GetRelatedTransactions is still
+// called for each of them. MaxRange is how many blocks are asked for at once, in place of
+// prefetch_window. Its blocks may have no parent hash, reorganizations are then not detected
+// and the chain relies on its confirmations.
+type RangeProducer interface {
+	GetBlocksByRange(from, to int) ([]Block, error)
+	MaxRange() int
+}
+
 // Consumer ...
 type Consumer interface {
 	GetCurrentBlockInfo() (Block, error)
+	// GetBlockInfoByHeight returns a block previously stored by NewBlock, or ErrBlockNotFound
+	GetBlockInfoByHeight(height int) (Block, error)
 	NewBlock(block Block, txs []Transaction) error
+	// Rollback reverts every stored block above height, along with its transactions
+	Rollback(height int) error
 }
 
+// TxStatus the outcome of a transaction
+type TxStatus string
+
+const (
+	TxStatusSuccess TxStatus = "success"
+	TxStatusFailed  TxStatus = "failed"
+	TxStatusPending TxStatus = "pending" // waiting in the mempool, see PendingProducer
+)
+
 type Transaction interface {
 	GetHash() string
-	TokenAddress() string
-	FromAddress() string
-	ToAddress() string
-	Amount() string
+	Status() TxStatus
+	// Fee paid by the sender in the smallest unit of the coin, nil if unknown
+	Fee() *big.Int
+	// Transfers every movement of value in the transaction
+	Transfers() []*Transfer
 }
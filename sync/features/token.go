@@ -0,0 +1,10 @@
+package features
+
+// TokenInfo metadata of a token contract. Fields the contract does not implement are empty,
+// Decimals is -1 when unknown.
+type TokenInfo struct {
+	Address  string `json:"address"`
+	Name     string `json:"name"`
+	Symbol   string `json:"symbol"`
+	Decimals int    `json:"decimals"`
+}
@@ -0,0 +1,76 @@
+package features
+
+import (
+	"math/big"
+	"strings"
+)
+
+// TradeType how the value of a transfer was moved
+type TradeType string
+
+const (
+	TradeTypeCoinbase          TradeType = "coinbase"
+	TradeTypeTransfer          TradeType = "transfer"
+	TradeTypeChange            TradeType = "change"   // a UTXO output paying back to an address of the inputs
+	TradeTypeInternal          TradeType = "internal" // value moved by a contract call, found by tracing
+	TradeTypeContract          TradeType = "contract" // fungible token
+	TradeTypeNFTTransfer       TradeType = "nft_transfer"
+	TradeTypeNFTTransferSingle TradeType = "nft_transfer_single"
+	TradeTypeNFTTransferBatch  TradeType = "nft_transfer_batch"
+	TradeTypeMainCoinContract  TradeType = "main_coin_contract" // the chain coin moved through its token contract
+	TradeTypeDeposit           TradeType = "deposit"            // coins minted on a rollup for a deposit made on L1
+)
+
+// UnknownDecimals the Decimals of a transfer whose asset decimals are not known
+const UnknownDecimals = -1
+
+// Transfer one movement of value inside a transaction
+type Transfer struct {
+	// Index position in the transaction, e.g. the output index or the log index
+	Index string
+	// Asset the token contract, empty for the chain coin
+	Asset string
+	// Token metadata of Asset, nil for the chain coin or when not resolved
+	Token *TokenInfo
+	// TokenID of a non-fungible token, empty for coins and fungible tokens
+	TokenID string
+	From    string
+	To      string
+	// Amount in the smallest unit of the asset, e.g. satoshi or wei
+	Amount *big.Int
+	// Decimals of the asset, UnknownDecimals if not known
+	Decimals  int
+	TradeType TradeType
+}
+
+// ScaledAmount the amount in whole units of the asset, empty if its decimals are unknown
+func (t *Transfer) ScaledAmount() string {
+	if t.Decimals == UnknownDecimals {
+		return ""
+	}
+	return formatUnits(t.Amount, t.Decimals)
+}
+
+// formatUnits formats an integer of the smallest unit as a decimal string, trailing zeros are
+// dropped, e.g. 150000000 with 8 decimals is 1.5
+func formatUnits(value *big.Int, decimals int) string {
+	if value == nil {
+		return ""
+	}
+	digits := new(big.Int).Abs(value).String()
+	if decimals <= 0 {
+		return value.String()
+	}
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	whole, fraction := digits[:len(digits)-decimals], strings.TrimRight(digits[len(digits)-decimals:], "0")
+	result := whole
+	if len(fraction) > 0 {
+		result += "." + fraction
+	}
+	if value.Sign() < 0 {
+		result = "-" + result
+	}
+	return result
+}
@@ -0,0 +1,42 @@
+package features
+
+import "math/big"
+
+// UTXOInput a previous output spent by a UTXO transaction
+type UTXOInput struct {
+	PrevTxHash string
+	PrevIndex  int64
+	Address    string
+	Value      *big.Int
+}
+
+// UTXOOutput an output created by a UTXO transaction
+type UTXOOutput struct {
+	Index   int64
+	Address string
+	Value   *big.Int
+}
+
+// CoinbaseReward what the miner of a block claims in its coinbase transaction
+type CoinbaseReward struct {
+	Miner   string
+	Subsidy *big.Int
+	Fees    *big.Int // total fees of the other transactions in the block
+}
+
+func (r *CoinbaseReward) Total() *big.Int {
+	return new(big.Int).Add(r.Subsidy, r.Fees)
+}
+
+// UTXOTransaction is implemented by transactions of UTXO chains, which move value from a set
+// of inputs to a set of outputs. Inputs and Outputs list them in transaction order, NetFlows
+// sums them per address and Coinbase is the reward claimed by a coinbase transaction.
+type UTXOTransaction interface {
+	Transaction
+	Inputs() []UTXOInput
+	Outputs() []UTXOOutput
+	// NetFlows returns the value each address received minus the value it spent
+	NetFlows() map[string]*big.Int
+	// Coinbase the block reward, nil unless this is a coinbase transaction
+	Coinbase() *CoinbaseReward
+}
@@ -1,35 +1,43 @@
 package btc
 
 import (
-	"sync"
+	"github.com/sirupsen/logrus"
 
+	"gitlab.com/sync/common/checkpoint"
 	"gitlab.com/sync/common/config"
 
 	"gitlab.com/sync/features"
 )
 
 type consumer struct {
-	sync.RWMutex
-	currentHeight int
+	*features.BaseConsumer
+	chain string
 }
 
-func NewConsumer(cfg *config.Consumer) (features.Consumer, error) {
+func NewConsumer(chain string, cfg *config.Consumer, store checkpoint.Store) (features.Consumer, error) {
+	base, err := features.NewBaseConsumer(chain, cfg.StartHeight, cfg.ReorgDepth, store)
+	if err != nil {
+		return nil, err
+	}
 	return &consumer{
-		currentHeight: cfg.StartHeight,
+		BaseConsumer: base,
+		chain:        chain,
 	}, nil
 }
 
-func (c *consumer) GetCurrentBlockInfo() (features.Block, error) {
-	c.Lock()
-	defer c.Unlock()
-	return &features.BlockInfo{
-		Height: c.currentHeight,
-	}, nil
-}
-
-func (c *consumer) NewBlock(b features.Block, txs []features.Transaction) error {
-	c.Lock()
-	defer c.Unlock()
-	c.currentHeight = b.GetHeight()
+func (c *consumer) NewPendingTransactions(txs []features.Transaction) error {
+	for _, tx := range txs {
+		for _, tf := range tx.Transfers() {
+			logrus.
+				WithField("chain", c.chain).
+				WithField("transaction_hash", tx.GetHash()).
+				WithField("status", tx.Status()).
+				WithField("index", tf.Index).
+				WithField("from_address", tf.From).
+				WithField("to_address", tf.To).
+				WithField("amount", tf.Amount).
+				Info("pending transfer")
+		}
+	}
 	return nil
 }
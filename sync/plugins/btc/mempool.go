@@ -0,0 +1,93 @@
+package btc
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/sync/common/net/rpc"
+	"gitlab.com/sync/features"
+)
+
+// GetPendingTransactions diffs getrawmempool against the previous call. The first call only
+// records the mempool, the transactions already in it are not reported.
+func (p *producer) GetPendingTransactions() ([]features.Transaction, []string, error) {
+	var hashes []string
+	if err := p.client.SyncCall(&hashes, getRawMempoolMethod); err != nil {
+		return nil, nil, err
+	}
+	mempool := make(map[string]struct{}, len(hashes))
+	if p.mempool == nil {
+		for _, hash := range hashes {
+			mempool[hash] = struct{}{}
+		}
+		p.mempool = mempool
+		logrus.
+			WithField("chain", p.chain).
+			WithField("txs", len(hashes)).
+			Info("mempool recorded, follow the transactions entering it")
+		return nil, nil, nil
+	}
+	var added []string
+	for _, hash := range hashes {
+		mempool[hash] = struct{}{}
+		if _, ok := p.mempool[hash]; !ok {
+			added = append(added, hash)
+		}
+	}
+	var removed []string
+	for hash := range p.mempool {
+		if _, ok := mempool[hash]; !ok {
+			removed = append(removed, hash)
+		}
+	}
+
+	var result []features.Transaction
+	if len(added) > 0 {
+		txes, err := p.mempoolTxes(added)
+		if err != nil {
+			return nil, nil, err
+		}
+		// the inputs of mempool transactions are resolved like those of a block
+		if err := p.convertTxes(&jsonBlock{Txes: txes}); err != nil {
+			return nil, nil, err
+		}
+		for _, tx := range txes {
+			tx.pending = true
+			result = append(result, tx)
+		}
+	}
+	p.mempool = mempool
+	return result, removed, nil
+}
+
+// mempoolTxes gets the transactions of hashes, leaving out those that left the mempool meanwhile.
+// Any other error fails the whole call.
+func (p *producer) mempoolTxes(hashes []string) ([]*jsonTransaction, error) {
+	request := make([]rpc.BatchElem, 0, len(hashes))
+	for _, hash := range hashes {
+		request = append(request, rpc.BatchElem{Method: getRawTransactionMethod, Args: []interface{}{hash, 1}, Result: new(jsonTransaction)})
+	}
+	err := p.client.BatchSyncCall(request)
+	txes := make([]*jsonTransaction, 0, len(hashes))
+	var missing int
+	for _, elem := range request {
+		if elem.Error == nil {
+			txes = append(txes, elem.Result.(*jsonTransaction))
+			continue
+		}
+		if !rpc.IsNotFound(elem.Error) {
+			return nil, elem.Error
+		}
+		missing++
+	}
+	// element errors only, the batch itself went through
+	if err != nil && missing == 0 {
+		return nil, err
+	}
+	if missing > 0 {
+		logrus.
+			WithField("chain", p.chain).
+			WithField("missing", missing).
+			Debug("transactions left the mempool before they were fetched")
+	}
+	return txes, nil
+}
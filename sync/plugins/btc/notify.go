@@ -0,0 +1,58 @@
+package btc
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/sync/common/net/zmq"
+)
+
+const hashBlockTopic = "hashblock"
+
+// SubscribeNewHeads follows the hashblock notifications bitcoind publishes at notify_url,
+// set with -zmqpubhashblock
+func (p *producer) SubscribeNewHeads(done <-chan struct{}) (<-chan struct{}, error) {
+	if len(p.cfg.NotifyURL) == 0 {
+		return nil, errors.New("notify_url is not set")
+	}
+	sub, err := zmq.Subscribe(p.cfg.NotifyURL, time.Millisecond*time.Duration(p.cfg.Timeout), hashBlockTopic)
+	if err != nil {
+		return nil, err
+	}
+	heads := make(chan struct{}, 1)
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-done:
+		case <-stopped:
+		}
+		sub.Close()
+	}()
+	go func() {
+		defer close(heads)
+		defer close(stopped)
+		for {
+			frames, err := sub.Receive()
+			if err != nil {
+				select {
+				case <-done:
+				default:
+					logrus.
+						WithField("chain", p.chain).
+						Warnf("zmq subscription dropped: %v", err)
+				}
+				return
+			}
+			if len(frames) == 0 || string(frames[0]) != hashBlockTopic {
+				continue
+			}
+			select {
+			case heads <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return heads, nil
+}
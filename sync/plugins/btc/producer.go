@@ -2,27 +2,61 @@ package btc
 
 import (
 	"fmt"
+	"sync/atomic"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"gitlab.com/sync/common/config"
 	"gitlab.com/sync/common/net/rpc"
+	"gitlab.com/sync/common/script"
 	"gitlab.com/sync/features"
 )
 
 type producer struct {
-	cfg    *config.Producer
-	client *rpc.Client
+	chain   string
+	cfg     *config.Producer
+	client  *rpc.Client
+	profile *profile
+	network *script.Network
+
+	verbosity atomic.Int32 // getblock verbosity supported by the node, 3 or 2, 0 until detected
+
+	mempool map[string]struct{} // transaction ids of the previous getrawmempool
 }
 
-func NewProducer(cfg *config.Producer) (features.Producer, error) {
+func NewProducer(chain string, cfg *config.Producer) (features.Producer, error) {
+	prof, err := getProfile(chain, cfg)
+	if err != nil {
+		return nil, err
+	}
+	network, err := script.GetNetwork(prof.coin, cfg.Network)
+	if err != nil {
+		return nil, err
+	}
 	client, err := rpc.DialInsecureSkipVerify(cfg.URL, "", "", rpc.JSONRPCVersion2)
 	if err != nil {
 		return nil, err
 	}
+	if cfg.Timeout > 0 {
+		client.SetTimeout(time.Millisecond * time.Duration(cfg.Timeout))
+	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	client.SetMaxBatchNum(batchSize).
+		SetBatchConcurrency(cfg.BatchConcurrency).
+		SetBatchRetry(cfg.BatchRetry)
 	p := &producer{
-		cfg:    cfg,
-		client: client,
+		chain:   chain,
+		cfg:     cfg,
+		client:  client,
+		profile: prof,
+		network: network,
+	}
+	if !prof.prevoutBlocks {
+		p.verbosity.Store(txBlockVerbosity)
 	}
 	return p, nil
 }
@@ -30,42 +64,110 @@ func NewProducer(cfg *config.Producer) (features.Producer, error) {
 func (p *producer) GetChainHeight() (int, error) {
 	var height int
 	if err := p.client.SyncCall(&height, getChainHeightMethod); err != nil {
-		return 0, nil
+		return 0, err
 	}
 	return height, nil
 }
 
 func (p *producer) GetBlockByHeight(height int) (features.Block, error) {
 	var hash string
-	b := new(jsonBlock)
 	err := p.client.SyncCall(&hash, getBlockHashMethod, height)
 	if err != nil {
 		return nil, err
 	}
-	err = p.client.SyncCall(&b, getBlockMethod, hash)
+	if p.profile.txidBlocks {
+		return p.getTxidBlock(hash)
+	}
+	b, err := p.getBlock(hash)
 	if err != nil {
 		return nil, err
 	}
 	return b, nil
 }
 
-func (p *producer) GetRelatedTransactions(block features.Block) ([]features.Transaction, error) {
-	b := block.(*jsonBlock)
-	var err error
-	b.txes, err = p.batchTxes(b.Txes)
+// getTxidBlock gets a block listing transaction ids only, then its transactions
+func (p *producer) getTxidBlock(hash string) (features.Block, error) {
+	b := new(jsonTxidBlock)
+	err := p.client.SyncCall(&b, getBlockMethod, hash, true)
 	if err != nil {
 		return nil, err
 	}
-	err = p.convertTxes(b)
+	if len(b.Txids) > 0 {
+		b.Txes, err = p.batchTxes(b.Txids)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &b.jsonBlock, nil
+}
+
+// getBlock gets a block with the prevout of every input when the node can return them. Until
+// that is known verbosity 3 is asked for: nodes before it refuse it as an invalid parameter,
+// or answer as for verbosity 2, which the first block with a spent input tells.
+func (p *producer) getBlock(hash string) (*jsonBlock, error) {
+	verbosity := int(p.verbosity.Load())
+	detecting := verbosity == 0
+	if detecting {
+		verbosity = prevoutBlockVerbosity
+	}
+	b := new(jsonBlock)
+	err := p.client.SyncCall(&b, getBlockMethod, hash, verbosity)
 	if err != nil {
+		if detecting && rpc.IsInvalidParams(err) {
+			p.detectVerbosity(txBlockVerbosity, err.Error())
+			return p.getBlock(hash)
+		}
 		return nil, err
 	}
+	if detecting {
+		if has, known := b.hasPrevouts(); known && has {
+			p.detectVerbosity(prevoutBlockVerbosity, "prevouts returned")
+		} else if known {
+			p.detectVerbosity(txBlockVerbosity, "no prevouts returned")
+		}
+	}
+	return b, nil
+}
+
+// detectVerbosity settles the getblock verbosity the first time it is found out
+func (p *producer) detectVerbosity(verbosity int, reason string) {
+	if p.verbosity.CompareAndSwap(0, int32(verbosity)) {
+		logrus.
+			WithField("chain", p.chain).
+			WithField("reason", reason).
+			Infof("use getblock verbosity %d", verbosity)
+	}
+}
+
+func (p *producer) GetRelatedTransactions(block features.Block) ([]features.Transaction, error) {
+	b := block.(*jsonBlock)
+	err := p.convertTxes(b)
+	if err != nil {
+		return nil, err
+	}
+	if b.AuxPoW != nil {
+		logrus.
+			WithField("chain", p.chain).
+			WithField("block_hash", b.Hash).
+			WithField("parent_block", b.AuxPoW.parentHash()).
+			WithField("parent_coinbase", b.AuxPoW.Tx.Hash).
+			Debug("merge mined")
+	}
 	var result []features.Transaction
-	for _, v := range b.txes {
+	for _, v := range b.Txes {
 		result = append(result, v)
+		if v.reward != nil {
+			logrus.
+				WithField("chain", p.chain).
+				WithField("transaction_hash", v.Hash).
+				WithField("miner", v.reward.Miner).
+				WithField("subsidy", v.reward.Subsidy).
+				WithField("fees", v.reward.Fees).
+				Info("coinbase")
+		}
 		for _, vin := range v.Vin {
 			logrus.
-				WithField("chain", "btc").
+				WithField("chain", p.chain).
 				WithField("transaction_hash", v.Hash).
 				WithField("address", vin.address).
 				WithField("amount", vin.value).
@@ -74,7 +176,7 @@ func (p *producer) GetRelatedTransactions(block features.Block) ([]features.Tran
 		}
 		for _, vout := range v.Vout {
 			logrus.
-				WithField("chain", "btc").
+				WithField("chain", p.chain).
 				WithField("transaction_hash", v.Hash).
 				WithField("address", vout.toAddress).
 				WithField("amount", vout.value).
@@ -86,16 +188,9 @@ func (p *producer) GetRelatedTransactions(block features.Block) ([]features.Tran
 }
 
 func (p *producer) batchTxes(hashes []string) ([]*jsonTransaction, error) {
-	// TODO: btc每个块中交易数量太多，容易超时，为了演示只取前10条
-	var max int
-	if len(hashes) > 10 {
-		max = 10
-	} else {
-		max = len(hashes)
-	}
-	request := make([]rpc.BatchElem, 0, max)
-	txes := make([]*jsonTransaction, max)
-	for i, hash := range hashes[:max] {
+	request := make([]rpc.BatchElem, 0, len(hashes))
+	txes := make([]*jsonTransaction, len(hashes))
+	for i, hash := range hashes {
 		txes[i] = new(jsonTransaction)
 		request = append(request, rpc.BatchElem{Method: getRawTransactionMethod, Args: []interface{}{hash, 1}, Result: txes[i]})
 	}
@@ -108,7 +203,7 @@ func (p *producer) batchTxes(hashes []string) ([]*jsonTransaction, error) {
 			return nil, elem.Error
 		}
 	}
-	for index, hash := range hashes[:max] {
+	for index, hash := range hashes {
 		if hash != txes[index].Hash {
 			err = fmt.Errorf("bacth tx err: %d/%d wanted %s, but got %s",
 				index+1, len(hashes), hash, txes[index].Hash)
@@ -118,23 +213,24 @@ func (p *producer) batchTxes(hashes []string) ([]*jsonTransaction, error) {
 	return txes, nil
 }
 
+// convertTxes resolves the address and value of every input. Prevouts returned inline by
+// getblock verbosity 3 are used directly, the rest are looked up with getrawtransaction.
 func (p *producer) convertTxes(b *jsonBlock) error {
-	err := b.convertWithoutCheckNode()
-	if err != nil {
-		return err
-	}
-	txes, err := p.batchTxes(b.getUncheckedVinAddressPreHashes())
-	if err != nil {
-		return err
-	}
-
-	err = b.convertWithCheckNode(txes)
+	err := b.convertWithoutCheckNode(p.network, p.profile.skipMWEB)
 	if err != nil {
 		return err
 	}
-	txes, err = p.batchTxes(b.getUncheckedVinValuePreHashes())
-	if err != nil {
-		return err
+	hashes := b.getUncheckedVinPreHashes()
+	if len(hashes) > 0 {
+		txes, err := p.batchTxes(hashes)
+		if err != nil {
+			return err
+		}
+		err = b.convertWithCheckNode(txes, p.network)
+		if err != nil {
+			return err
+		}
 	}
-	return b.fillFromAddress()
+	b.computeFees(p.profile.subsidy)
+	return nil
 }
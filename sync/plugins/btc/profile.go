@@ -0,0 +1,72 @@
+package btc
+
+import (
+	"fmt"
+	"math/big"
+
+	"gitlab.com/sync/common/config"
+)
+
+// profile what sets a UTXO chain apart from bitcoin
+type profile struct {
+	coin string // network family of script.GetNetwork
+	// prevoutBlocks the node may return prevouts inline with getblock verbosity 3
+	prevoutBlocks bool
+	// txidBlocks getblock only takes a verbose flag and lists transaction ids, which are
+	// fetched with getrawtransaction (dogecoin)
+	txidBlocks bool
+	// skipMWEB MWEB inputs and outputs are confidential and left out (litecoin)
+	skipMWEB bool
+	// subsidy the newly minted coins a block at height may claim, nil if it can not be computed
+	subsidy func(height int) *big.Int
+}
+
+// profiles of the supported coins by name
+var profiles = map[string]*profile{
+	"btc":  {coin: "btc", prevoutBlocks: true, subsidy: halvingSubsidy(50*100_000_000, 210_000)},
+	"ltc":  {coin: "ltc", skipMWEB: true, subsidy: halvingSubsidy(50*100_000_000, 840_000)},
+	"doge": {coin: "doge", txidBlocks: true, subsidy: dogeSubsidy},
+	"bch":  {coin: "bch", subsidy: halvingSubsidy(50*100_000_000, 210_000)},
+}
+
+// getProfile the profile of the coin set in cfg, else of the plugin type or the chain name
+func getProfile(chain string, cfg *config.Producer) (*profile, error) {
+	if len(cfg.Coin) > 0 {
+		result, ok := profiles[cfg.Coin]
+		if !ok {
+			return nil, fmt.Errorf("unsupported coin %s", cfg.Coin)
+		}
+		return result, nil
+	}
+	for _, name := range []string{cfg.Type, chain} {
+		if result, ok := profiles[name]; ok {
+			return result, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown coin of chain %s, set coin to btc, ltc, doge or bch", chain)
+}
+
+// halvingSubsidy the bitcoin schedule: initial satoshi halved every interval blocks
+func halvingSubsidy(initial int64, interval int) func(height int) *big.Int {
+	return func(height int) *big.Int {
+		halvings := height / interval
+		if halvings >= 64 {
+			return new(big.Int)
+		}
+		return big.NewInt(initial >> halvings)
+	}
+}
+
+// dogeSubsidy blocks before 145,000 had a random reward, then it halved every 100,000 blocks
+// down to a fixed 10,000 DOGE from block 600,000
+func dogeSubsidy(height int) *big.Int {
+	const coin = 100_000_000
+	switch {
+	case height < 145_000:
+		return nil
+	case height < 600_000:
+		return big.NewInt(500_000 * coin >> (height / 100_000))
+	default:
+		return big.NewInt(10_000 * coin)
+	}
+}
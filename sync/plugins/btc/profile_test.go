@@ -0,0 +1,30 @@
+package btc
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestDogeSubsidy(t *testing.T) {
+	const coin = 100_000_000
+	tests := []struct {
+		height  int
+		subsidy *big.Int
+	}{
+		{144_999, nil},
+		{145_000, big.NewInt(250_000 * coin)},
+		{199_999, big.NewInt(250_000 * coin)},
+		{200_000, big.NewInt(125_000 * coin)},
+		{599_999, big.NewInt(15_625 * coin)},
+		{600_000, big.NewInt(10_000 * coin)},
+	}
+	for _, tt := range tests {
+		got := dogeSubsidy(tt.height)
+		switch {
+		case tt.subsidy == nil && got != nil:
+			t.Errorf("height %d: got %s, want nil", tt.height, got)
+		case tt.subsidy != nil && (got == nil || got.Cmp(tt.subsidy) != 0):
+			t.Errorf("height %d: got %v, want %s", tt.height, got, tt.subsidy)
+		}
+	}
+}
@@ -1,10 +1,16 @@
 package btc
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"math/big"
+	"strconv"
+	"strings"
 
 	"gitlab.com/sync/common"
+	"gitlab.com/sync/common/script"
+	"gitlab.com/sync/features"
 
 	"github.com/pkg/errors"
 )
@@ -14,24 +20,63 @@ const (
 	getBlockHashMethod      = "getblockhash"
 	getBlockMethod          = "getblock"
 	getRawTransactionMethod = "getrawtransaction"
+	getRawMempoolMethod     = "getrawmempool"
+
+	txBlockVerbosity      = 2 // getblock returns decoded transactions
+	prevoutBlockVerbosity = 3 // getblock also returns the prevout of every input, since Bitcoin Core 25.0
+
+	defaultBatchSize = 100
+
+	coinDecimals = 8
+
+	coinbaseTxType = "1"
 )
 
 type jsonBlock struct {
-	Hash          string   `json:"hash"`
-	Height        int      `json:"height"`
-	Time          int      `json:"time"`
-	PrevBlockHash string   `json:"previousblockhash"`
-	Txes          []string `json:"tx"`
-	PosFlag       string   `json:"flags"`
-	NTx           int      `json:"nTx"` //ltc
-
-	txes  []*jsonTransaction
+	Hash          string             `json:"hash"`
+	Height        int                `json:"height"`
+	Time          int                `json:"time"`
+	PrevBlockHash string             `json:"previousblockhash"`
+	Txes          []*jsonTransaction `json:"tx"`
+	PosFlag       string             `json:"flags"`
+	NTx           int                `json:"nTx"`    //ltc
+	AuxPoW        *jsonAuxPoW        `json:"auxpow"` // merge mined blocks, doge
+
 	miner string
 
 	Chainlock bool `json:"chainlock"` // SYSCOIN 独有的，用于判断区块是否不可篡改
 
 }
 
+// jsonTxidBlock a block whose tx lists transaction ids, getblock with verbose true
+type jsonTxidBlock struct {
+	jsonBlock
+	Txids []string `json:"tx"`
+}
+
+// jsonAuxPoW the proof that a parent chain block was mined for this block too
+type jsonAuxPoW struct {
+	Tx struct {
+		Hash string `json:"txid"`
+	} `json:"tx"` // coinbase of the parent block, not a transaction of this chain
+	ChainIndex  int    `json:"chainindex"`
+	ParentBlock string `json:"parentblock"` // hex encoded parent block header
+}
+
+// parentHash the hash of the parent block header, empty if it can not be decoded
+func (a *jsonAuxPoW) parentHash() string {
+	header, err := hex.DecodeString(a.ParentBlock)
+	if err != nil || len(header) != 80 {
+		return ""
+	}
+	first := sha256.Sum256(header)
+	hash := sha256.Sum256(first[:])
+	for i, j := 0, len(hash)-1; i < j; i, j = i+1, j-1 {
+		hash[i], hash[j] = hash[j], hash[i]
+	}
+	return hex.EncodeToString(hash[:])
+}
+
 func (b *jsonBlock) GetHash() string {
 	return b.Hash
 }
@@ -48,9 +93,28 @@ func (b *jsonBlock) GetBlockTime() int {
 	return b.Time
 }
 
-func (b *jsonBlock) convertWithoutCheckNode() error {
-	for _, tx := range b.txes {
-		err := tx.convert(b.Height, false)
+func (b *jsonBlock) GetTxHashes() []string {
+	result := make([]string, 0, len(b.Txes))
+	for _, tx := range b.Txes {
+		result = append(result, tx.Hash)
+	}
+	return result
+}
+
+// hasPrevouts reports whether the inputs carry their prevout, known once the block has an
+// input that is not a coinbase
+func (b *jsonBlock) hasPrevouts() (has bool, known bool) {
+	for _, tx := range b.Txes {
+		if len(tx.Vin) > 0 && len(tx.Vin[0].CoinBase) == 0 {
+			return tx.Vin[0].Prevout != nil, true
+		}
+	}
+	return false, false
+}
+
+func (b *jsonBlock) convertWithoutCheckNode(net *script.Network, skipMWEB bool) error {
+	for _, tx := range b.Txes {
+		err := tx.convert(b.Height, net, skipMWEB)
 		if err != nil {
 			return errors.Wrapf(err, "tx %s", tx.Hash)
 		}
@@ -58,14 +122,14 @@ func (b *jsonBlock) convertWithoutCheckNode() error {
 	return nil
 }
 
-func (b *jsonBlock) convertWithCheckNode(txes []*jsonTransaction) error {
+func (b *jsonBlock) convertWithCheckNode(txes []*jsonTransaction, net *script.Network) error {
 	txMap := make(map[string]*jsonTransaction, len(txes))
 	for _, tx := range txes {
 		txMap[tx.Hash] = tx
 	}
-	for _, tx := range b.txes {
+	for _, tx := range b.Txes {
 		for _, vin := range tx.Vin {
-			if vin.gotAddress {
+			if vin.gotAddress && vin.gotValue {
 				continue
 			}
 			preTx, ok := txMap[vin.PrevTxHash]
@@ -78,11 +142,13 @@ func (b *jsonBlock) convertWithCheckNode(txes []*jsonTransaction) error {
 					vin.PrevTxHash, vin.PrevVoutIndex, tx.Hash, vin.index)
 			}
 			vout := preTx.Vout[vin.PrevVoutIndex]
-			err := vout.convert()
+			err := vout.convert(net)
 			if err != nil {
 				return err
 			}
-			vin.address = vout.toAddress
+			if len(vout.toAddress) > 0 || !vin.gotAddress {
+				vin.address = vout.toAddress
+			}
 			vin.value = vout.value
 			vin.gotAddress = true
 			vin.gotValue = true
@@ -91,11 +157,11 @@ func (b *jsonBlock) convertWithCheckNode(txes []*jsonTransaction) error {
 	return nil
 }
 
-func (b *jsonBlock) getUncheckedVinAddressPreHashes() (hashes []string) {
-	hashMap := make(map[string]bool, len(b.txes))
-	for _, tx := range b.txes {
+func (b *jsonBlock) getUncheckedVinPreHashes() (hashes []string) {
+	hashMap := make(map[string]bool, len(b.Txes))
+	for _, tx := range b.Txes {
 		for _, vin := range tx.Vin {
-			if !vin.gotAddress {
+			if !vin.gotAddress || !vin.gotValue {
 				hashMap[vin.PrevTxHash] = true
 			}
 		}
@@ -107,29 +173,36 @@ func (b *jsonBlock) getUncheckedVinAddressPreHashes() (hashes []string) {
 	return
 }
 
-func (b *jsonBlock) getUncheckedVinValuePreHashes() (hashes []string) {
-	hashMap := make(map[string]bool, len(b.txes))
-	for _, tx := range b.txes {
-		for _, vin := range tx.Vin {
-			if !vin.gotValue {
-				hashMap[vin.PrevTxHash] = true
-			}
+// computeFees sets the fee of every transaction, then the reward claimed by the coinbase
+func (b *jsonBlock) computeFees(subsidy func(height int) *big.Int) {
+	totalFees := new(big.Int)
+	var coinbase *jsonTransaction
+	for _, tx := range b.Txes {
+		if tx.txType == coinbaseTxType {
+			coinbase = tx
+			continue
+		}
+		tx.fee = tx.computeFee()
+		if tx.fee != nil {
+			totalFees.Add(totalFees, tx.fee)
 		}
 	}
-	hashes = make([]string, 0, len(hashMap))
-	for hash := range hashMap {
-		hashes = append(hashes, hash)
+	if coinbase == nil {
+		return
 	}
-	return
-}
-
-func (b *jsonBlock) fillFromAddress() error {
-	for _, tx := range b.txes {
-		if err := tx.fillFromAddress(); err != nil {
-			return err
-		}
+	coinbase.fee = new(big.Int)
+	b.miner = coinbase.minerAddress()
+	blockSubsidy := subsidy(b.Height)
+	if blockSubsidy == nil {
+		// unknown schedule, take what the coinbase claimed beyond the fees
+		blockSubsidy = new(big.Int).Neg(coinbase.computeFee())
+		blockSubsidy.Sub(blockSubsidy, totalFees)
+	}
+	coinbase.reward = &features.CoinbaseReward{
+		Miner:   b.miner,
+		Subsidy: blockSubsidy,
+		Fees:    totalFees,
 	}
-	return nil
 }
 
 type jsonTransaction struct {
@@ -145,9 +218,12 @@ type jsonTransaction struct {
 	//VShieldedOutput []json.RawMessage `json:"vShieldedOutput"`
 	vinRelated bool
 	shielded   bool // set for zec。true 表示这是匿名交易
+	mweb       bool // MWEB inputs or outputs were left out, set for ltc
+	pending    bool // in the mempool, not yet mined
 
 	txType string
 	fee    *big.Int
+	reward *features.CoinbaseReward
 
 	writeDeposit bool // only work for wallet and bcd, for airdrop
 }
@@ -156,60 +232,210 @@ func (t *jsonTransaction) GetHash() string {
 	return t.Hash
 }
 
-func (t *jsonTransaction) TokenAddress() string {
-	return ""
+// Transfers one transfer per output that carries value, from the input address spending the most.
+// Outputs paying back to an address of the inputs are marked as change.
+func (t *jsonTransaction) Transfers() []*features.Transfer {
+	inputs := t.inputValues()
+	from := t.mainInputAddress(inputs)
+	result := make([]*features.Transfer, 0, len(t.Vout))
+	for _, vout := range t.Vout {
+		if vout.value == nil || vout.value.Sign() == 0 {
+			continue
+		}
+		tradeType := features.TradeTypeTransfer
+		if t.txType == coinbaseTxType {
+			tradeType = features.TradeTypeCoinbase
+		} else if _, ok := inputs[vout.toAddress]; ok && len(vout.toAddress) > 0 {
+			tradeType = features.TradeTypeChange
+		}
+		result = append(result, &features.Transfer{
+			Index:     strconv.FormatInt(vout.Index, 10),
+			From:      from,
+			To:        vout.toAddress,
+			Amount:    vout.value,
+			Decimals:  coinDecimals,
+			TradeType: tradeType,
+		})
+	}
+	return result
 }
 
-func (t *jsonTransaction) FromAddress() string {
-	return ""
+// mainInputAddress the input address spending the most value, the first in input order on a
+// tie, empty for coinbase
+func (t *jsonTransaction) mainInputAddress(inputs map[string]*big.Int) string {
+	var from string
+	max := new(big.Int)
+	for _, vin := range t.Vin {
+		if value, ok := inputs[vin.address]; ok && value.Cmp(max) > 0 {
+			from, max = vin.address, value
+		}
+	}
+	return from
 }
 
-func (t *jsonTransaction) ToAddress() string {
-	return ""
+func (t *jsonTransaction) Inputs() []features.UTXOInput {
+	result := make([]features.UTXOInput, 0, len(t.Vin))
+	for _, vin := range t.Vin {
+		result = append(result, features.UTXOInput{
+			PrevTxHash: vin.PrevTxHash,
+			PrevIndex:  vin.PrevVoutIndex,
+			Address:    vin.address,
+			Value:      vin.value,
+		})
+	}
+	return result
 }
 
-func (t *jsonTransaction) Amount() string {
-	return ""
+func (t *jsonTransaction) Outputs() []features.UTXOOutput {
+	result := make([]features.UTXOOutput, 0, len(t.Vout))
+	for _, vout := range t.Vout {
+		result = append(result, features.UTXOOutput{
+			Index:   vout.Index,
+			Address: vout.toAddress,
+			Value:   vout.value,
+		})
+	}
+	return result
+}
+
+func (t *jsonTransaction) NetFlows() map[string]*big.Int {
+	flows := make(map[string]*big.Int)
+	for address, value := range t.outputValues() {
+		flows[address] = value
+	}
+	for address, value := range t.inputValues() {
+		if flow, ok := flows[address]; ok {
+			flow.Sub(flow, value)
+		} else {
+			flows[address] = new(big.Int).Neg(value)
+		}
+	}
+	return flows
+}
+
+// inputValues sums the spent value per input address
+func (t *jsonTransaction) inputValues() map[string]*big.Int {
+	result := make(map[string]*big.Int, len(t.Vin))
+	for _, vin := range t.Vin {
+		addValue(result, vin.address, vin.value)
+	}
+	return result
+}
+
+// outputValues sums the received value per output address
+func (t *jsonTransaction) outputValues() map[string]*big.Int {
+	result := make(map[string]*big.Int, len(t.Vout))
+	for _, vout := range t.Vout {
+		addValue(result, vout.toAddress, vout.value)
+	}
+	return result
+}
+
+func addValue(values map[string]*big.Int, address string, value *big.Int) {
+	if value == nil {
+		return
+	}
+	if sum, ok := values[address]; ok {
+		sum.Add(sum, value)
+	} else {
+		values[address] = new(big.Int).Set(value)
+	}
 }
 
-func (t *jsonTransaction) convert(height int, isTest bool) error {
+func (t *jsonTransaction) convert(height int, net *script.Network, skipMWEB bool) error {
 	if t.isCoinbase() {
-		t.txType = "1"
+		t.txType = coinbaseTxType
 		t.Vin = []*jsonVin{}
 	}
+	if skipMWEB {
+		t.skipMWEB()
+	}
 
 	for i, vin := range t.Vin {
-		vin.convertAddressWithoutCheckNode(isTest, int64(i))
+		vin.convertAddressWithoutCheckNode(net, int64(i))
 	}
 
 	for _, vout := range t.Vout {
-		if err := vout.convert(); err != nil {
+		if err := vout.convert(net); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (t *jsonTransaction) isCoinbase() bool {
-	if len(t.Vin) == 1 {
-		if len(t.Vin[0].CoinBase) > 0 {
-			return true
+// skipMWEB drops the MWEB inputs and outputs, their addresses and values are confidential
+func (t *jsonTransaction) skipMWEB() {
+	vins := t.Vin[:0]
+	for _, vin := range t.Vin {
+		if !vin.Ismweb {
+			vins = append(vins, vin)
 		}
 	}
-	return false
+	vouts := t.Vout[:0]
+	for _, vout := range t.Vout {
+		if !vout.Ismweb {
+			vouts = append(vouts, vout)
+		}
+	}
+	t.mweb = len(vins) < len(t.Vin) || len(vouts) < len(t.Vout)
+	t.Vin, t.Vout = vins, vouts
 }
 
-func (t *jsonTransaction) fillFromAddress() error {
-	result := []*jsonVout{}
+// Status a transaction included in a block always succeeded
+func (t *jsonTransaction) Status() features.TxStatus {
+	if t.pending {
+		return features.TxStatusPending
+	}
+	return features.TxStatusSuccess
+}
+
+// Fee what the inputs spend beyond the outputs, zero for a coinbase, nil while an input value
+// is unknown or MWEB parts were left out
+func (t *jsonTransaction) Fee() *big.Int {
+	return t.fee
+}
+
+// Coinbase the block reward claimed by a coinbase transaction, nil for any other
+func (t *jsonTransaction) Coinbase() *features.CoinbaseReward {
+	return t.reward
+}
+
+func (t *jsonTransaction) computeFee() *big.Int {
+	if t.mweb {
+		return nil
+	}
+	fee := new(big.Int)
+	for _, vin := range t.Vin {
+		if vin.value == nil {
+			return nil
+		}
+		fee.Add(fee, vin.value)
+	}
 	for _, vout := range t.Vout {
-		for _, vin := range t.Vin {
-			vout.value = vin.value
-			vout.fromAddress = vin.address
-			result = append(result, vout)
+		if vout.value != nil {
+			fee.Sub(fee, vout.value)
 		}
 	}
-	t.Vout = result
-	return nil
+	return fee
+}
+
+// minerAddress the first output of a coinbase paying a standard address
+func (t *jsonTransaction) minerAddress() string {
+	for _, vout := range t.Vout {
+		if len(vout.toAddress) > 0 && vout.value != nil && vout.value.Sign() > 0 {
+			return vout.toAddress
+		}
+	}
+	return ""
+}
+
+func (t *jsonTransaction) isCoinbase() bool {
+	if len(t.Vin) == 1 {
+		if len(t.Vin[0].CoinBase) > 0 {
+			return true
+		}
+	}
+	return false
 }
 
 type scriptSig struct {
@@ -218,31 +444,66 @@ type scriptSig struct {
 }
 
 type jsonVin struct {
-	PrevTxHash    string    `json:"txid"`
-	PrevVoutIndex int64     `json:"vout"`
-	CoinBase      string    `json:"coinbase"`
-	CoinBase2     string    `json:"coinbase2"` // set for sbtc
-	Sequence      int       `json:"sequence"`
-	ScriptSig     scriptSig `json:"scriptSig"`
-	ValueSat      uint64    `json:"valueSat"` // set for xzc, look for tx: 378bb77b494a5ed9a07250b58598418006f6869aae176d9ae045579cb6b6f97b
-	Ismweb        bool      `json:"ismweb"`   //ltc
+	PrevTxHash    string       `json:"txid"`
+	PrevVoutIndex int64        `json:"vout"`
+	CoinBase      string       `json:"coinbase"`
+	CoinBase2     string       `json:"coinbase2"` // set for sbtc
+	Sequence      int          `json:"sequence"`
+	ScriptSig     scriptSig    `json:"scriptSig"`
+	Witness       []string     `json:"txinwitness"`
+	ValueSat      uint64       `json:"valueSat"` // set for xzc, look for tx: 378bb77b494a5ed9a07250b58598418006f6869aae176d9ae045579cb6b6f97b
+	Ismweb        bool         `json:"ismweb"`   //ltc
+	Prevout       *jsonPrevout `json:"prevout"`  // getblock verbosity 3
 
 	index   int64
 	address string
-	value   string
+	value   *big.Int // satoshi
 
 	gotAddress bool
 	gotValue   bool
 }
 
-func (v *jsonVin) convertAddressWithoutCheckNode(isTest bool, index int64) {
+func (v *jsonVin) convertAddressWithoutCheckNode(net *script.Network, index int64) {
 	v.index = index
+	if v.Prevout != nil {
+		if err := v.Prevout.convert(v, net); err == nil {
+			return
+		}
+	}
 	var err error
-	v.address, err = common.GetAddressFromScriptSig(v.ScriptSig.Asm, isTest)
+	v.address, err = script.AddressFromInput(v.ScriptSig.Hex, v.Witness, net)
 	v.gotAddress = (err == nil)
+	if v.ValueSat > 0 {
+		v.value = new(big.Int).SetUint64(v.ValueSat)
+		v.gotValue = true
+	}
+}
+
+type jsonPrevout struct {
+	Generated    bool             `json:"generated"`
+	Height       int              `json:"height"`
+	Value        json.RawMessage  `json:"value"`
+	ScriptPubKey jsonScriptPubKey `json:"scriptPubKey"`
+}
+
+func (p *jsonPrevout) convert(vin *jsonVin, net *script.Network) error {
+	address, err := getAddressFromScript(p.ScriptPubKey, net)
+	if err != nil {
+		return err
+	}
+	value, err := getSatoshiValue(p.Value)
+	if err != nil {
+		return err
+	}
+	vin.address = address
+	vin.value = value
+	vin.gotAddress = true
+	vin.gotValue = true
+	return nil
 }
 
 type jsonScriptPubKey struct {
+	Hex       string   `json:"hex"`
 	Address   string   `json:"address"` //v22+版本btc程序新增
 	Addresses []string `json:"addresses"`
 	Type      string   `json:"type"`
@@ -255,16 +516,15 @@ type jsonVout struct {
 	Ismweb       bool             `json:"ismweb"` //ltc
 
 	isConverted bool
-	fromAddress string
 	toAddress   string
-	value       string
+	value       *big.Int // satoshi
 	UUID        string
 }
 
-func (v *jsonVout) convert() error {
+func (v *jsonVout) convert(net *script.Network) error {
 	// address
 	var err error
-	v.toAddress, err = getAddressFromScript(v.ScriptPubKey)
+	v.toAddress, err = getAddressFromScript(v.ScriptPubKey, net)
 	if err != nil {
 		return err
 	}
@@ -279,10 +539,31 @@ func (v *jsonVout) convert() error {
 	return nil
 }
 
-func getAddressFromScript(script jsonScriptPubKey) (string, error) {
-	return script.Address, nil
+// getAddressFromScript derives the address from the script itself so it follows the configured
+// network, the node's answer is only used for scripts without a standard address
+func getAddressFromScript(pubKey jsonScriptPubKey, net *script.Network) (string, error) {
+	if len(pubKey.Hex) == 0 {
+		return getAddressFromNode(pubKey), nil
+	}
+	address, err := script.AddressFromScriptPubKey(pubKey.Hex, net)
+	if err != nil {
+		return "", err
+	}
+	if len(address) == 0 {
+		address = getAddressFromNode(pubKey)
+	}
+	return address, nil
+}
+
+// getAddressFromNode the address decoded by the node, nodes before v22 only return addresses
+func getAddressFromNode(pubKey jsonScriptPubKey) string {
+	if len(pubKey.Address) > 0 {
+		return pubKey.Address
+	}
+	return strings.Join(pubKey.Addresses, ",")
 }
 
-func getSatoshiValue(value json.RawMessage) (string, error) {
-	return string(value), nil
+// getSatoshiValue converts a coin amount such as 0.00012 to satoshi without going through float
+func getSatoshiValue(value json.RawMessage) (*big.Int, error) {
+	return common.ParseDecimal(string(value), coinDecimals)
 }
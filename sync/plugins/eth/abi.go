@@ -0,0 +1,55 @@
+package eth
+
+import (
+	"encoding/hex"
+	"math/big"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/sync/common"
+)
+
+const abiWordSize = 32
+
+// decodeABIData decodes the hex data of a log or call result
+func decodeABIData(data string) ([]byte, error) {
+	result, err := hex.DecodeString(common.RemoveHexPrefix(data))
+	return result, errors.Wrapf(err, "decode abi data %s", data)
+}
+
+// decodeABIUint reads the uint256 at word index of ABI encoded data
+func decodeABIUint(data []byte, index int) (*big.Int, error) {
+	start := index * abiWordSize
+	if index < 0 || start+abiWordSize > len(data) {
+		return nil, errors.Errorf("abi word %d out of range, data has %d bytes", index, len(data))
+	}
+	return new(big.Int).SetBytes(data[start : start+abiWordSize]), nil
+}
+
+// decodeABIUintArray reads a dynamic uint256[] whose byte offset is stored at word index
+func decodeABIUintArray(data []byte, index int) ([]*big.Int, error) {
+	offset, err := decodeABIUint(data, index)
+	if err != nil {
+		return nil, err
+	}
+	if !offset.IsInt64() || offset.Int64()%abiWordSize != 0 {
+		return nil, errors.Errorf("invalid abi array offset %s", offset)
+	}
+	start := int(offset.Int64() / abiWordSize)
+	length, err := decodeABIUint(data, start)
+	if err != nil {
+		return nil, err
+	}
+	if !length.IsInt64() || length.Int64() > int64(len(data)/abiWordSize) {
+		return nil, errors.Errorf("invalid abi array length %s", length)
+	}
+	result := make([]*big.Int, 0, length.Int64())
+	for i := 1; i <= int(length.Int64()); i++ {
+		value, err := decodeABIUint(data, start+i)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, value)
+	}
+	return result, nil
+}
@@ -0,0 +1,52 @@
+package eth
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/sha3"
+
+	"gitlab.com/sync/common"
+)
+
+const addressLength = 20
+
+// checksumAddress formats a hex address in EIP-55 mixed case. Anything that is not a
+// 20 byte address, e.g. an empty contract creation target, is returned as is.
+func checksumAddress(address string) string {
+	lower := strings.ToLower(common.RemoveHexPrefix(address))
+	if len(lower) != addressLength*2 {
+		return address
+	}
+	if _, err := hex.DecodeString(lower); err != nil {
+		return address
+	}
+	h := sha3.NewLegacyKeccak256()
+	h.Write([]byte(lower))
+	hash := h.Sum(nil)
+	result := []byte(lower)
+	for i, c := range result {
+		// a letter is upper case when the matching nibble of the hash is 8 or more
+		nibble := hash[i/2] >> 4
+		if i%2 == 1 {
+			nibble = hash[i/2] & 0x0f
+		}
+		if c >= 'a' && nibble >= 8 {
+			result[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(result)
+}
+
+// topicToAddress takes the address out of an indexed event argument, left padded to 32 bytes
+func topicToAddress(topic string) (string, error) {
+	data, err := hex.DecodeString(common.RemoveHexPrefix(topic))
+	if err != nil {
+		return "", errors.Wrapf(err, "decode topic %s", topic)
+	}
+	if len(data) != abiWordSize {
+		return "", errors.Errorf("topic %s is not %d bytes", topic, abiWordSize)
+	}
+	return checksumAddress(hex.EncodeToString(data[abiWordSize-addressLength:])), nil
+}
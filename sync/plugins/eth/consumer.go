@@ -1,46 +1,100 @@
 package eth
 
 import (
-	"sync"
+	"math/big"
 
 	"github.com/sirupsen/logrus"
 
+	"gitlab.com/sync/common/checkpoint"
 	"gitlab.com/sync/common/config"
 	"gitlab.com/sync/features"
 )
 
 type consumer struct {
-	sync.RWMutex
-	currentHeight int
+	*features.BaseConsumer
+	chain string
 }
 
-func NewConsumer(cfg *config.Consumer) (features.Consumer, error) {
+func NewConsumer(chain string, cfg *config.Consumer, store checkpoint.Store) (features.Consumer, error) {
+	base, err := features.NewBaseConsumer(chain, cfg.StartHeight, cfg.ReorgDepth, store)
+	if err != nil {
+		return nil, err
+	}
 	return &consumer{
-		currentHeight: cfg.StartHeight,
-	}, nil
-}
-
-func (c *consumer) GetCurrentBlockInfo() (features.Block, error) {
-	c.Lock()
-	defer c.Unlock()
-	return &features.BlockInfo{
-		Height: c.currentHeight,
+		BaseConsumer: base,
+		chain:        chain,
 	}, nil
 }
 
 func (c *consumer) NewBlock(b features.Block, txs []features.Transaction) error {
-	c.Lock()
-	defer c.Unlock()
-	c.currentHeight = b.GetHeight()
+	if err := c.BaseConsumer.NewBlock(b, txs); err != nil {
+		return err
+	}
 	for _, tx := range txs {
 		logrus.
-			WithField("chain", "eth").
+			WithField("chain", c.chain).
 			WithField("transaction_hash", tx.GetHash()).
-			WithField("token_address", tx.ToAddress()).
-			WithField("from_address", tx.FromAddress()).
-			WithField("to_address", tx.ToAddress()).
-			WithField("amount", tx.Amount()).
-			Infof("erc20 transactions on block %d", b.GetHeight())
+			WithField("status", tx.Status()).
+			WithField("fee", tx.Fee()).
+			WithField("burnt_fee", burntFee(tx)).
+			WithField("tip", tipFee(tx)).
+			Infof("transactions on block %d", b.GetHeight())
+		for _, tf := range tx.Transfers() {
+			logrus.
+				WithField("chain", c.chain).
+				WithField("transaction_hash", tx.GetHash()).
+				WithField("index", tf.Index).
+				WithField("trade_type", tf.TradeType).
+				WithField("token_address", tf.Asset).
+				WithField("token_symbol", tokenSymbol(tf)).
+				WithField("from_address", tf.From).
+				WithField("to_address", tf.To).
+				WithField("amount", tf.Amount).
+				WithField("scaled_amount", tf.ScaledAmount()).
+				WithField("token_id", tf.TokenID).
+				Infof("transfer on block %d", b.GetHeight())
+		}
+	}
+	return nil
+}
+
+func tokenSymbol(tf *features.Transfer) string {
+	if token := tf.Token; token != nil {
+		return token.Symbol
+	}
+	return ""
+}
+
+func burntFee(tx features.Transaction) *big.Int {
+	if evm, ok := tx.(features.EVMTransaction); ok && evm.EVMFee() != nil {
+		return evm.EVMFee().Burnt
+	}
+	return nil
+}
+
+func tipFee(tx features.Transaction) *big.Int {
+	if evm, ok := tx.(features.EVMTransaction); ok && evm.EVMFee() != nil {
+		return evm.EVMFee().Tip
+	}
+	return nil
+}
+
+func (c *consumer) NewPendingTransactions(txs []features.Transaction) error {
+	for _, tx := range txs {
+		for _, tf := range tx.Transfers() {
+			logrus.
+				WithField("chain", c.chain).
+				WithField("transaction_hash", tx.GetHash()).
+				WithField("status", tx.Status()).
+				WithField("trade_type", tf.TradeType).
+				WithField("token_address", tf.Asset).
+				WithField("token_symbol", tokenSymbol(tf)).
+				WithField("from_address", tf.From).
+				WithField("to_address", tf.To).
+				WithField("amount", tf.Amount).
+				WithField("scaled_amount", tf.ScaledAmount()).
+				Info("pending transfer")
+		}
 	}
 	return nil
 }
@@ -0,0 +1,238 @@
+package eth
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/sync/common"
+	"gitlab.com/sync/features"
+)
+
+const (
+	blocksMode = "blocks" // full blocks and receipts
+	logsMode   = "logs"   // token transfer events only
+
+	defaultLogRange = 2000
+)
+
+// tooManyResults what providers answer when an eth_getLogs range holds too many logs
+var tooManyResults = []string{
+	"more than",
+	"too many",
+	"limit exceeded",
+	"size exceeded",
+	"range is too",
+	"range too",
+	"block range",
+	"exceed maximum",
+	"query timeout",
+}
+
+type logFilter struct {
+	FromBlock string     `json:"fromBlock"`
+	ToBlock   string     `json:"toBlock"`
+	Address   []string   `json:"address,omitempty"`
+	Topics    [][]string `json:"topics"`
+}
+
+// logBlock a block known only by the transfer events in it. Its hash is taken from the logs
+// and stays empty when it has none, the parent hash is never known.
+type logBlock struct {
+	features.BlockInfo
+	txs []features.Transaction
+}
+
+// logProducer follows token transfers with eth_getLogs over block ranges instead of fetching
+// every block and receipt. Native transfers and fees are not seen, and reorgs can not be
+// detected, so it relies on confirmations.
+type logProducer struct {
+	*producer
+	tokens   []string
+	topics   []string // transfer events, including those of the system tokens of the chain
+	maxRange int
+
+	mu       sync.Mutex
+	logRange int // current blocks per eth_getLogs, shrunk when the provider refuses a range
+}
+
+func newLogProducer(p *producer) *logProducer {
+	maxRange := p.cfg.LogRange
+	if maxRange <= 0 {
+		maxRange = defaultLogRange
+	}
+	tokens := make([]string, 0, len(p.cfg.Tokens))
+	for _, token := range p.cfg.Tokens {
+		tokens = append(tokens, strings.ToLower(token))
+	}
+	topics := []string{transferEventHash, transferSingleEventHash, transferBatchEventHash}
+	for _, st := range p.profile.systemTokens {
+		if st.event != transferEventHash {
+			topics = append(topics, st.event)
+		}
+	}
+	return &logProducer{
+		producer: p,
+		tokens:   tokens,
+		topics:   topics,
+		maxRange: maxRange,
+		logRange: maxRange,
+	}
+}
+
+func (p *logProducer) GetBlockByHeight(height int) (features.Block, error) {
+	blocks, err := p.GetBlocksByRange(height, height)
+	if err != nil {
+		return nil, err
+	}
+	return blocks[0], nil
+}
+
+func (p *logProducer) GetRelatedTransactions(block features.Block) ([]features.Transaction, error) {
+	return block.(*logBlock).txs, nil
+}
+
+func (p *logProducer) GetBlocksByRange(from, to int) ([]features.Block, error) {
+	logs := make([][]receiptLogs, to-from+1)
+	for start := from; start <= to; {
+		end := min(start+p.currentRange()-1, to)
+		result, err := p.getLogs(start, end)
+		if err != nil {
+			if end > start && isTooManyResults(err) {
+				p.shrinkRange(end - start + 1)
+				continue
+			}
+			return nil, err
+		}
+		p.growRange()
+		for _, tLog := range result {
+			if tLog.Removed {
+				continue
+			}
+			height, err := common.DecodeHex(tLog.BlockNumber)
+			if err != nil {
+				return nil, errors.Wrapf(err, "log %s of tx %s block number", tLog.LogIndex, tLog.TransactionHash)
+			}
+			if int(height) < start || int(height) > end {
+				return nil, fmt.Errorf("log of block %d is out of range %d-%d", height, start, end)
+			}
+			logs[int(height)-from] = append(logs[int(height)-from], tLog)
+		}
+		start = end + 1
+	}
+
+	blocks := make([]features.Block, 0, len(logs))
+	var txs []*jsonTransaction
+	for i, blockLogs := range logs {
+		b, err := p.newLogBlock(from+i, blockLogs)
+		if err != nil {
+			return nil, err
+		}
+		for _, tx := range b.txs {
+			txs = append(txs, tx.(*jsonTransaction))
+		}
+		blocks = append(blocks, b)
+	}
+	// one lookup for the whole range
+	p.attachTokens(txs)
+	return blocks, nil
+}
+
+// MaxRange the blocks of one eth_getLogs at most, log_range
+func (p *logProducer) MaxRange() int {
+	return p.maxRange
+}
+
+func (p *logProducer) getLogs(from, to int) ([]receiptLogs, error) {
+	filter := &logFilter{
+		FromBlock: fmt.Sprintf("0x%x", from),
+		ToBlock:   fmt.Sprintf("0x%x", to),
+		Address:   p.tokens,
+		Topics:    [][]string{p.topics},
+	}
+	var result []receiptLogs
+	if err := p.client.SyncCall(&result, getLogs, filter); err != nil {
+		return nil, errors.Wrapf(err, "%s %d-%d", getLogs, from, to)
+	}
+	return result, nil
+}
+
+// newLogBlock groups the logs of one block by transaction, keeping their order
+func (p *logProducer) newLogBlock(height int, logs []receiptLogs) (*logBlock, error) {
+	b := &logBlock{BlockInfo: features.BlockInfo{Height: height}}
+	txs := make(map[string]*jsonTransaction)
+	txLogs := make(map[string][]receiptLogs)
+	var order []*jsonTransaction
+	for _, tLog := range logs {
+		if len(b.Hash) == 0 {
+			b.Hash = tLog.BlockHash
+		} else if b.Hash != tLog.BlockHash {
+			return nil, fmt.Errorf("block %d logs come from %s and %s", height, b.Hash, tLog.BlockHash)
+		}
+		if b.BlockTime == 0 && len(tLog.BlockTimestamp) > 0 {
+			blockTime, err := strconv.ParseUint(tLog.BlockTimestamp, 0, 64)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			b.BlockTime = int(blockTime)
+		}
+		tx, ok := txs[tLog.TransactionHash]
+		if !ok {
+			// logs are only kept for successful transactions
+			tx = &jsonTransaction{Hash: tLog.TransactionHash, hash: tLog.TransactionHash, receiptStatus: 1}
+			txs[tLog.TransactionHash] = tx
+			order = append(order, tx)
+		}
+		txLogs[tx.hash] = append(txLogs[tx.hash], tLog)
+	}
+	for _, tx := range order {
+		var err error
+		tx.tokenTfs, err = p.getTokenTransfer(tx, txLogs[tx.hash])
+		if err != nil {
+			return nil, err
+		}
+		if len(tx.tokenTfs) > 0 {
+			b.txs = append(b.txs, tx)
+		}
+	}
+	return b, nil
+}
+
+func (p *logProducer) currentRange() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.logRange
+}
+
+// shrinkRange halves the range that was refused
+func (p *logProducer) shrinkRange(refused int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.logRange = max(refused/2, 1)
+	logrus.
+		WithField("chain", p.chain).
+		WithField("log_range", p.logRange).
+		Warnf("%s range of %d blocks refused, shrink it", getLogs, refused)
+}
+
+// growRange widens the range by a quarter after a success, up to log_range. Growing slower
+// than it shrinks keeps it from bouncing on the provider limit.
+func (p *logProducer) growRange() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.logRange = min(p.logRange+p.logRange/4+1, p.maxRange)
+}
+
+func isTooManyResults(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, s := range tooManyResults {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package eth
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/sync/common/net/rpc"
+)
+
+// SubscribeNewHeads follows eth_subscribe("newHeads") on the websocket at notify_url
+func (p *producer) SubscribeNewHeads(done <-chan struct{}) (<-chan struct{}, error) {
+	if len(p.cfg.NotifyURL) == 0 {
+		return nil, errors.New("notify_url is not set")
+	}
+	client, err := rpc.DialWebSocket(p.cfg.NotifyURL, time.Millisecond*time.Duration(p.cfg.Timeout))
+	if err != nil {
+		return nil, err
+	}
+	notifications, err := client.Subscribe("eth", "newHeads")
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	heads := make(chan struct{}, 1)
+	go func() {
+		defer close(heads)
+		for {
+			select {
+			case <-done:
+				client.Close()
+				return
+			case _, ok := <-notifications:
+				if !ok {
+					return
+				}
+				// heads arriving while the last one is unread are one wake up
+				select {
+				case heads <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return heads, nil
+}
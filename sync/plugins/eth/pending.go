@@ -0,0 +1,143 @@
+package eth
+
+import (
+	"bytes"
+	"encoding/hex"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/sync/common/net/rpc"
+	"gitlab.com/sync/features"
+)
+
+const (
+	newPendingFilter = "eth_newPendingTransactionFilter"
+	getFilterChanges = "eth_getFilterChanges"
+	getTransaction   = "eth_getTransactionByHash"
+
+	pendingBlockTag = "pending"
+)
+
+// transferSelector selector of ERC-20 transfer(address,uint256)
+var transferSelector = []byte{0xa9, 0x05, 0x9c, 0xbb}
+
+// GetPendingTransactions follows the pool with a pending transaction filter, which only reports
+// new transactions. Nodes without one are polled for their pending block, diffed against the
+// previous poll, which also reports the transactions that left it.
+func (p *producer) GetPendingTransactions() ([]features.Transaction, []string, error) {
+	if !p.pendingByBlock {
+		result, err := p.getPendingByFilter()
+		if err == nil || !rpc.IsMethodNotFound(err) {
+			return result, nil, err
+		}
+		logrus.
+			WithField("chain", p.chain).
+			Warnf("pending transaction filter is not supported, poll the pending block: %v", err)
+		p.pendingByBlock = true
+	}
+	return p.getPendingByBlock()
+}
+
+func (p *producer) getPendingByFilter() ([]features.Transaction, error) {
+	if len(p.pendingFilter) == 0 {
+		if err := p.client.SyncCall(&p.pendingFilter, newPendingFilter); err != nil {
+			return nil, err
+		}
+	}
+	var hashes []string
+	if err := p.client.SyncCall(&hashes, getFilterChanges, p.pendingFilter); err != nil {
+		// the node uninstalls filters it has not been asked about for a while
+		p.pendingFilter = ""
+		return nil, err
+	}
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+	request := make([]rpc.BatchElem, 0, len(hashes))
+	txs := make([]*jsonTransaction, len(hashes))
+	for i, hash := range hashes {
+		request = append(request, rpc.BatchElem{Method: getTransaction, Args: []interface{}{hash}, Result: &txs[i]})
+	}
+	if err := p.client.BatchSyncCall(request); err != nil {
+		return nil, err
+	}
+	return p.pendingTransactions(txs)
+}
+
+func (p *producer) getPendingByBlock() ([]features.Transaction, []string, error) {
+	b := new(jsonBlock)
+	if err := p.client.SyncCall(b, getBlock, pendingBlockTag, true); err != nil {
+		return nil, nil, err
+	}
+	seen := make(map[string]struct{}, len(b.Transactions))
+	var added []*jsonTransaction
+	for _, tx := range b.Transactions {
+		seen[tx.Hash] = struct{}{}
+		if _, ok := p.pendingSeen[tx.Hash]; !ok {
+			added = append(added, tx)
+		}
+	}
+	var removed []string
+	for hash := range p.pendingSeen {
+		if _, ok := seen[hash]; !ok {
+			removed = append(removed, hash)
+		}
+	}
+	result, err := p.pendingTransactions(added)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.pendingSeen = seen
+	return result, removed, nil
+}
+
+// pendingTransactions converts the transactions of the pool, those mined or dropped before they
+// were fetched are nil and left out. Without a receipt only the value and ERC-20 transfer calls
+// are known, fees are not.
+func (p *producer) pendingTransactions(txs []*jsonTransaction) ([]features.Transaction, error) {
+	result := make([]features.Transaction, 0, len(txs))
+	var converted []*jsonTransaction
+	for _, tx := range txs {
+		if tx == nil {
+			continue
+		}
+		if err := tx.convert(); err != nil {
+			return nil, err
+		}
+		tx.pending = true
+		if tx.amount.Sign() > 0 {
+			tx.tfs = append(tx.tfs, &features.Transfer{
+				From:      tx.from,
+				To:        tx.to,
+				Amount:    tx.amount,
+				Decimals:  coinDecimals,
+				TradeType: features.TradeTypeTransfer,
+			})
+		}
+		if tf := p.getTransferCall(tx); tf != nil {
+			tx.tokenTfs = append(tx.tokenTfs, tf)
+		}
+		converted = append(converted, tx)
+		result = append(result, tx)
+	}
+	p.attachTokens(converted)
+	return result, nil
+}
+
+// getTransferCall decodes a call of ERC-20 transfer(to, value), nil for any other input
+func (p *producer) getTransferCall(tx *jsonTransaction) *features.Transfer {
+	input, err := decodeABIData(tx.Input)
+	if err != nil || len(input) != len(transferSelector)+2*abiWordSize || !bytes.HasPrefix(input, transferSelector) {
+		return nil
+	}
+	args := input[len(transferSelector):]
+	amount, _ := decodeABIUint(args, 1)
+	return &features.Transfer{
+		Asset:     tx.to,
+		From:      tx.from,
+		To:        checksumAddress("0x" + hex.EncodeToString(args[abiWordSize-addressLength:abiWordSize])),
+		Amount:    amount,
+		Decimals:  p.getTokenDecimals(tx.to),
+		TradeType: features.TradeTypeContract,
+	}
+}
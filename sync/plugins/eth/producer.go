@@ -2,8 +2,12 @@ package eth
 
 import (
 	"fmt"
+	"math/big"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 
 	"gitlab.com/sync/common"
 	"gitlab.com/sync/common/config"
@@ -12,22 +16,139 @@ import (
 )
 
 type producer struct {
-	cfg    *config.Producer
-	client *rpc.Client
+	chain         string
+	profile       *profile
+	cfg           *config.Producer
+	client        *rpc.Client
+	blockReceipts bool           // the node serves eth_getBlockReceipts
+	tokenDecimals map[string]int // lower case token address to decimals, from token_decimals
+	tokens        *tokenResolver // nil unless resolve_tokens is set
+
+	pendingFilter  string              // id of the pending transaction filter, installed on first poll
+	pendingByBlock bool                // the node has no pending transaction filter
+	pendingSeen    map[string]struct{} // transaction hashes of the previous pending block
 }
 
-func NewProducer(cfg *config.Producer) (features.Producer, error) {
+func NewProducer(chain string, cfg *config.Producer) (features.Producer, error) {
+	switch cfg.Trace {
+	case "", debugTraceMode, parityTraceMode:
+	default:
+		return nil, fmt.Errorf("unsupported trace mode %s", cfg.Trace)
+	}
+	switch cfg.Mode {
+	case "", blocksMode:
+	case logsMode:
+		if len(cfg.Trace) > 0 {
+			return nil, errors.New("trace is not available in logs mode")
+		}
+		if cfg.Pending {
+			// blocks built from logs do not list their transactions, pending ones could not be confirmed
+			return nil, errors.New("pending is not available in logs mode")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported mode %s", cfg.Mode)
+	}
+	switch cfg.ReceiptsMethod {
+	case "", blockReceiptsMethod, batchReceiptsMethod:
+	default:
+		return nil, fmt.Errorf("unsupported receipts method %s", cfg.ReceiptsMethod)
+	}
+	if cfg.ChainID <= 0 {
+		return nil, errors.New("chain_id is required, it is checked against eth_chainId")
+	}
+	if cfg.Pending && cfg.PendingExpiry <= 0 {
+		// the pending transaction filter does not report dropped transactions, they would be kept forever
+		return nil, errors.New("pending_expiry is required to follow pending transactions")
+	}
+	prof, err := newProfile(chain, cfg)
+	if err != nil {
+		return nil, err
+	}
 	client, err := rpc.DialInsecureSkipVerify(cfg.URL, "", "", rpc.JSONRPCVersion2)
 	if err != nil {
 		return nil, err
 	}
+	if cfg.Timeout > 0 {
+		client.SetTimeout(time.Millisecond * time.Duration(cfg.Timeout))
+	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	client.SetMaxBatchNum(batchSize).
+		SetBatchConcurrency(cfg.BatchConcurrency).
+		SetBatchRetry(cfg.BatchRetry)
 	p := &producer{
-		cfg:    cfg,
-		client: client,
+		chain:         chain,
+		profile:       prof,
+		cfg:           cfg,
+		client:        client,
+		tokenDecimals: make(map[string]int, len(cfg.TokenDecimals)),
+	}
+	for token, decimals := range cfg.TokenDecimals {
+		p.tokenDecimals[strings.ToLower(token)] = decimals
+	}
+	if cfg.ResolveTokens {
+		p.tokens, err = newTokenResolver(chain, client, cfg.TokenCache)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err = p.checkChainID(); err != nil {
+		return nil, err
+	}
+	if cfg.Mode == logsMode {
+		return newLogProducer(p), nil
+	}
+	switch cfg.ReceiptsMethod {
+	case blockReceiptsMethod:
+		p.blockReceipts = true
+	case batchReceiptsMethod:
+		p.blockReceipts = false
+	default:
+		p.blockReceipts = p.supportBlockReceipts()
 	}
 	return p, nil
 }
 
+// checkChainID makes sure the node serves the chain_id configured
+func (p *producer) checkChainID() error {
+	var res string
+	if err := p.client.SyncCall(&res, getChainID); err != nil {
+		return errors.Wrapf(err, "check chain id")
+	}
+	chainID, err := common.DecodeHex(res)
+	if err != nil {
+		return err
+	}
+	if int64(chainID) != p.cfg.ChainID {
+		return fmt.Errorf("node serves chain id %d, %d is configured", chainID, p.cfg.ChainID)
+	}
+	return nil
+}
+
+// supportBlockReceipts asks for the genesis receipts to find out whether the node has eth_getBlockReceipts
+func (p *producer) supportBlockReceipts() bool {
+	var receipts []jsonTransactionReceipt
+	err := p.client.SyncCall(&receipts, getReceipts, "0x0")
+	if err == nil {
+		logrus.
+			WithField("chain", p.chain).
+			Infof("fetch receipts with %s", getReceipts)
+		return true
+	}
+	if rpc.IsMethodNotFound(err) {
+		logrus.
+			WithField("chain", p.chain).
+			Infof("%s is not supported, fetch receipts with batched %s", getReceipts, getReceipt)
+	} else {
+		logrus.
+			WithField("chain", p.chain).
+			Warnf("can not detect %s, fall back to batched %s: %v", getReceipts, getReceipt, err)
+	}
+	return false
+}
+
 func (p *producer) GetChainHeight() (int, error) {
 	var res string
 	if err := p.client.SyncCallObject(&res, getBlockNumber, []bool{}); err != nil {
@@ -40,6 +161,20 @@ func (p *producer) GetChainHeight() (int, error) {
 	return int(height), nil
 }
 
+func (p *producer) GetFinalizedHeight() (int, error) {
+	var header struct {
+		Number string `json:"number"`
+	}
+	if err := p.client.SyncCall(&header, getBlock, finalizedBlockTag, false); err != nil {
+		return 0, err
+	}
+	height, err := common.DecodeHex(header.Number)
+	if err != nil {
+		return 0, err
+	}
+	return int(height), nil
+}
+
 func (p *producer) GetBlockByHeight(height int) (features.Block, error) {
 	b := new(jsonBlock)
 	err := p.client.SyncCall(b, getBlock, fmt.Sprintf("0x%x", height), true)
@@ -63,11 +198,7 @@ func (p *producer) GetRelatedTransactions(block features.Block) ([]features.Tran
 				return nil, err
 			}
 		}
-		hashes := make([]string, 0, len(b.Transactions))
-		for _, item := range b.Transactions {
-			hashes = append(hashes, item.Hash)
-		}
-		receipts, err := batchTransactionReceiptBatchSearch(p.client, hashes)
+		receipts, err := p.getReceipts(b)
 		if err != nil {
 			return nil, err
 		}
@@ -85,50 +216,324 @@ func (p *producer) GetRelatedTransactions(block features.Block) ([]features.Tran
 			}
 			b.receiptsMap[receipt.TransactionHash] = &receipts[index]
 		}
+		b.internalTfs, err = p.getInternalTransfers(b)
+		if err != nil {
+			return nil, err
+		}
 		b.receiptsReady = true
 	}
-	result := make([]features.Transaction, 0, len(b.Transactions))
+	related := make([]*jsonTransaction, 0, len(b.Transactions))
 	for _, tx := range b.Transactions {
 		receipt, ok := b.receiptsMap[tx.hash]
 		if !ok {
 			return nil, fmt.Errorf("tx %s not find match receipt", tx.hash)
 		}
-		err = tx.combineReceipt(receipt)
+		err = tx.combineReceipt(receipt, p.profile.baseFee(b), p.profile)
 		if err != nil {
 			return nil, err
 		}
+		tx.tfs = tx.getNativeTransfer()
 		if tx.receiptStatusSuccess() {
+			tx.tfs = append(tx.tfs, b.internalTfs[tx.hash]...)
 			tx.tokenTfs, err = p.getTokenTransfer(tx, receipt.Logs)
 			if err != nil {
 				return nil, err
 			}
+			tx.tokenTfs = dedupMainCoinTransfers(tx.tfs, tx.tokenTfs)
 		}
-		if len(tx.tokenTfs) > 0 {
-			result = append(result, tx)
+		// failed transactions are kept for the fee they still pay
+		if len(tx.tfs) > 0 || len(tx.tokenTfs) > 0 || !tx.receiptStatusSuccess() {
+			related = append(related, tx)
 		}
 	}
+	p.attachTokens(related)
+	result := make([]features.Transaction, 0, len(related))
+	for _, tx := range related {
+		result = append(result, tx)
+	}
 	return result, nil
 }
 
-func (p *producer) getTokenTransfer(rTx *jsonTransaction, logs []receiptLogs) ([]*transfer, error) {
-	result := make([]*transfer, 0)
+// attachTokens sets the token metadata of every token transfer, if resolve_tokens is set.
+// Configured token_decimals take precedence over the resolved ones.
+func (p *producer) attachTokens(txs []*jsonTransaction) {
+	if p.tokens == nil {
+		return
+	}
+	var addresses []string
+	for _, tx := range txs {
+		for _, tf := range tx.tokenTfs {
+			if len(tf.Asset) > 0 {
+				addresses = append(addresses, tf.Asset)
+			}
+		}
+	}
+	if len(addresses) == 0 {
+		return
+	}
+	infos := p.tokens.resolve(addresses)
+	for _, tx := range txs {
+		for _, tf := range tx.tokenTfs {
+			if len(tf.Asset) == 0 {
+				continue
+			}
+			tf.Token = infos[strings.ToLower(tf.Asset)]
+			if tf.Token != nil && tf.TradeType == features.TradeTypeContract && tf.Decimals == features.UnknownDecimals {
+				tf.Decimals = tf.Token.Decimals
+			}
+		}
+	}
+}
+
+func (p *producer) getTokenTransfer(rTx *jsonTransaction, logs []receiptLogs) ([]*features.Transfer, error) {
+	result := make([]*features.Transfer, 0)
 	// filter by transaction log event
 	for _, tLog := range logs {
-		if len(tLog.Topics) == 3 && tLog.Topics[0] == transferEventHash {
-			result = append(result, &transfer{
-				txHash:         rTx.hash,
-				assetChainName: tLog.Address,
-				from:           tLog.Topics[1],
-				to:             tLog.Topics[2],
-				amount:         tLog.Data,
-				index:          tLog.LogIndex,
-				tradeType:      contractTradeType,
-			})
+		if len(tLog.Topics) == 0 {
+			continue
+		}
+		if st := p.profile.getSystemToken(tLog.Address); st != nil {
+			tfs, err := getMainCoinTransfer(rTx, tLog, st)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, tfs...)
+			continue
+		}
+		switch {
+		case len(tLog.Topics) == 3 && tLog.Topics[0] == transferEventHash:
+			tfs, err := getFungibleTransfer(rTx, tLog)
+			if err != nil {
+				return nil, err
+			}
+			for _, tf := range tfs {
+				tf.Decimals = p.getTokenDecimals(tf.Asset)
+			}
+			result = append(result, tfs...)
+		case len(tLog.Topics) == 4 && tLog.Topics[0] == transferEventHash:
+			tfs, err := getNFTTransfer(rTx, tLog)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, tfs...)
+		case len(tLog.Topics) == 4 && tLog.Topics[0] == transferSingleEventHash:
+			tfs, err := getNFTTransferSingle(rTx, tLog)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, tfs...)
+		case len(tLog.Topics) == 4 && tLog.Topics[0] == transferBatchEventHash:
+			tfs, err := getNFTTransferBatch(rTx, tLog)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, tfs...)
 		}
 	}
 	return result, nil
 }
 
+// getTokenDecimals the decimals configured for a token, features.UnknownDecimals if none
+func (p *producer) getTokenDecimals(token string) int {
+	if decimals, ok := p.tokenDecimals[strings.ToLower(token)]; ok {
+		return decimals
+	}
+	return features.UnknownDecimals
+}
+
+// getMainCoinTransfer decodes the transfer event of a system token as a transfer of the chain coin
+func getMainCoinTransfer(rTx *jsonTransaction, tLog receiptLogs, st *systemToken) ([]*features.Transfer, error) {
+	if tLog.Topics[0] != st.event || len(tLog.Topics) != st.fromTopic+2 {
+		return nil, nil
+	}
+	from, to, err := getLogAddresses(rTx, tLog, st.fromTopic)
+	if err != nil {
+		return nil, err
+	}
+	data, err := decodeABIData(tLog.Data)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := decodeABIUint(data, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "tx %s log %s amount", rTx.hash, tLog.LogIndex)
+	}
+	return []*features.Transfer{{
+		From:      from,
+		To:        to,
+		Amount:    amount,
+		Index:     tLog.LogIndex,
+		Decimals:  coinDecimals,
+		TradeType: features.TradeTypeMainCoinContract,
+	}}, nil
+}
+
+// dedupMainCoinTransfers drops the main coin transfers logged by a system token that repeat a
+// native transfer already found from the transaction value or its trace, matching each one once
+func dedupMainCoinTransfers(native, tokenTfs []*features.Transfer) []*features.Transfer {
+	matched := make([]bool, len(native))
+	result := tokenTfs[:0]
+	for _, tf := range tokenTfs {
+		duplicate := false
+		if tf.TradeType == features.TradeTypeMainCoinContract {
+			for i, n := range native {
+				if !matched[i] && n.From == tf.From && n.To == tf.To && n.Amount.Cmp(tf.Amount) == 0 {
+					matched[i], duplicate = true, true
+					break
+				}
+			}
+		}
+		if !duplicate {
+			result = append(result, tf)
+		}
+	}
+	return result
+}
+
+// getLogAddresses decodes the from and to addresses of a transfer event, starting at topic index
+func getLogAddresses(rTx *jsonTransaction, tLog receiptLogs, index int) (from, to string, err error) {
+	from, err = topicToAddress(tLog.Topics[index])
+	if err != nil {
+		return "", "", errors.Wrapf(err, "tx %s log %s from", rTx.hash, tLog.LogIndex)
+	}
+	to, err = topicToAddress(tLog.Topics[index+1])
+	if err != nil {
+		return "", "", errors.Wrapf(err, "tx %s log %s to", rTx.hash, tLog.LogIndex)
+	}
+	return from, to, nil
+}
+
+// getFungibleTransfer decodes ERC-20 Transfer(from, to, value), value is not indexed
+func getFungibleTransfer(rTx *jsonTransaction, tLog receiptLogs) ([]*features.Transfer, error) {
+	from, to, err := getLogAddresses(rTx, tLog, 1)
+	if err != nil {
+		return nil, err
+	}
+	data, err := decodeABIData(tLog.Data)
+	if err != nil {
+		return nil, err
+	}
+	// a few old tokens log the value unpadded
+	amount := new(big.Int).SetBytes(data)
+	if len(data) >= abiWordSize {
+		amount, _ = decodeABIUint(data, 0)
+	}
+	return []*features.Transfer{{
+		Asset:     checksumAddress(tLog.Address),
+		From:      from,
+		To:        to,
+		Amount:    amount,
+		Index:     tLog.LogIndex,
+		Decimals:  features.UnknownDecimals,
+		TradeType: features.TradeTypeContract,
+	}}, nil
+}
+
+// getNFTTransfer decodes ERC-721 Transfer(from, to, tokenId), every argument is indexed
+func getNFTTransfer(rTx *jsonTransaction, tLog receiptLogs) ([]*features.Transfer, error) {
+	from, to, err := getLogAddresses(rTx, tLog, 1)
+	if err != nil {
+		return nil, err
+	}
+	tokenID, err := common.GetHexNumber(tLog.Topics[3])
+	if err != nil {
+		return nil, errors.Wrapf(err, "tx %s log %s token id", rTx.hash, tLog.LogIndex)
+	}
+	return []*features.Transfer{{
+		Asset:     checksumAddress(tLog.Address),
+		From:      from,
+		To:        to,
+		Amount:    big.NewInt(1),
+		TokenID:   tokenID.String(),
+		Index:     tLog.LogIndex,
+		TradeType: features.TradeTypeNFTTransfer,
+	}}, nil
+}
+
+// getNFTTransferSingle decodes ERC-1155 TransferSingle(operator, from, to, id, value),
+// id and value are not indexed
+func getNFTTransferSingle(rTx *jsonTransaction, tLog receiptLogs) ([]*features.Transfer, error) {
+	from, to, err := getLogAddresses(rTx, tLog, 2)
+	if err != nil {
+		return nil, err
+	}
+	data, err := decodeABIData(tLog.Data)
+	if err != nil {
+		return nil, err
+	}
+	id, err := decodeABIUint(data, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "tx %s log %s id", rTx.hash, tLog.LogIndex)
+	}
+	value, err := decodeABIUint(data, 1)
+	if err != nil {
+		return nil, errors.Wrapf(err, "tx %s log %s value", rTx.hash, tLog.LogIndex)
+	}
+	return []*features.Transfer{{
+		Asset:     checksumAddress(tLog.Address),
+		From:      from,
+		To:        to,
+		Amount:    value,
+		TokenID:   id.String(),
+		Index:     tLog.LogIndex,
+		TradeType: features.TradeTypeNFTTransferSingle,
+	}}, nil
+}
+
+// getNFTTransferBatch decodes ERC-1155 TransferBatch(operator, from, to, ids, values) into one
+// transfer per id, indexed as logIndex:position
+func getNFTTransferBatch(rTx *jsonTransaction, tLog receiptLogs) ([]*features.Transfer, error) {
+	from, to, err := getLogAddresses(rTx, tLog, 2)
+	if err != nil {
+		return nil, err
+	}
+	data, err := decodeABIData(tLog.Data)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := decodeABIUintArray(data, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "tx %s log %s ids", rTx.hash, tLog.LogIndex)
+	}
+	values, err := decodeABIUintArray(data, 1)
+	if err != nil {
+		return nil, errors.Wrapf(err, "tx %s log %s values", rTx.hash, tLog.LogIndex)
+	}
+	if len(ids) != len(values) {
+		return nil, errors.Errorf("tx %s log %s has %d ids but %d values",
+			rTx.hash, tLog.LogIndex, len(ids), len(values))
+	}
+	result := make([]*features.Transfer, 0, len(ids))
+	for i := range ids {
+		result = append(result, &features.Transfer{
+			Asset:     checksumAddress(tLog.Address),
+			From:      from,
+			To:        to,
+			Amount:    values[i],
+			TokenID:   ids[i].String(),
+			Index:     fmt.Sprintf("%s:%d", tLog.LogIndex, i),
+			TradeType: features.TradeTypeNFTTransferBatch,
+		})
+	}
+	return result, nil
+}
+
+// getReceipts returns the receipts of all transactions in the block
+func (p *producer) getReceipts(b *jsonBlock) ([]jsonTransactionReceipt, error) {
+	if !p.blockReceipts {
+		hashes := make([]string, 0, len(b.Transactions))
+		for _, item := range b.Transactions {
+			hashes = append(hashes, item.Hash)
+		}
+		return batchTransactionReceiptBatchSearch(p.client, hashes)
+	}
+	var receipts []jsonTransactionReceipt
+	if err := p.client.SyncCall(&receipts, getReceipts, fmt.Sprintf("0x%x", b.height)); err != nil {
+		return nil, errors.Wrapf(err, "%s %d", getReceipts, b.height)
+	}
+	return receipts, nil
+}
+
 func batchTransactionReceiptBatchSearch(client *rpc.Client, hashes []string) ([]jsonTransactionReceipt, error) {
 	batchList := make([]rpc.BatchElem, 0, len(hashes))
 	receiptList := make([]jsonTransactionReceipt, len(hashes))
@@ -0,0 +1,97 @@
+package eth
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"gitlab.com/sync/common"
+	"gitlab.com/sync/common/config"
+)
+
+// profile what sets an EVM chain apart from ethereum
+type profile struct {
+	// opStack the chain is an OP-stack rollup: receipts carry the L1 data fee
+	// and deposit transactions mint coins bridged from L1
+	opStack bool
+	// noEIP1559 the base fee of blocks is not burnt, or there is none
+	noEIP1559 bool
+	// systemTokens contracts logging transfers of the chain coin, by address without 0x
+	systemTokens map[string]*systemToken
+}
+
+// systemToken a contract that logs the chain coin moving as if it were a token
+type systemToken struct {
+	event     string // topic of the event recording a transfer, other events are dropped
+	fromTopic int    // topic index of from, to follows it
+}
+
+var (
+	transferSystemToken    = &systemToken{event: transferEventHash, fromTopic: 1}
+	logTransferSystemToken = &systemToken{event: logTransferEventHash, fromTopic: 2}
+
+	// system token events by their name in config
+	systemTokenEvents = map[string]*systemToken{
+		"transfer":     transferSystemToken,
+		"log_transfer": logTransferSystemToken,
+	}
+)
+
+// profiles of the chains known by plugin type or name, any other chain starts from ethereum's
+var profiles = map[string]*profile{
+	"optimism": {opStack: true},
+	"base":     {opStack: true},
+	// every MATIC/POL movement is logged by LogTransfer, the ERC-20 Transfer of MRC20.transfer repeats it
+	"polygon": {systemTokens: map[string]*systemToken{maticMRC20: logTransferSystemToken}},
+	// CELO moved by value or through the GoldToken interface is logged as an ERC-20 Transfer
+	"celo": {systemTokens: map[string]*systemToken{celoGoldTokenProxy: transferSystemToken}},
+}
+
+// newProfile the profile of the plugin type, else of the chain name, with the feature flags of
+// its producer section applied
+func newProfile(chain string, cfg *config.Producer) (*profile, error) {
+	result := &profile{systemTokens: make(map[string]*systemToken)}
+	for _, name := range []string{cfg.Type, chain} {
+		known, ok := profiles[name]
+		if !ok {
+			continue
+		}
+		result.opStack = known.opStack
+		result.noEIP1559 = known.noEIP1559
+		for address, st := range known.systemTokens {
+			result.systemTokens[address] = st
+		}
+		break
+	}
+	if cfg.OPStack {
+		result.opStack = true
+	}
+	if cfg.EIP1559 != nil {
+		result.noEIP1559 = !*cfg.EIP1559
+	}
+	for _, token := range cfg.SystemTokens {
+		st, ok := systemTokenEvents[token.Event]
+		if !ok {
+			return nil, fmt.Errorf("unsupported system token event %s of %s", token.Event, token.Address)
+		}
+		address := strings.ToLower(common.RemoveHexPrefix(token.Address))
+		if len(address) != addressLength*2 {
+			return nil, fmt.Errorf("invalid system token address %s", token.Address)
+		}
+		result.systemTokens[address] = st
+	}
+	return result, nil
+}
+
+// getSystemToken the system token at address, nil if it is not one
+func (p *profile) getSystemToken(address string) *systemToken {
+	return p.systemTokens[strings.ToLower(common.RemoveHexPrefix(address))]
+}
+
+// baseFee the base fee burnt in block b, nil if none
+func (p *profile) baseFee(b *jsonBlock) *big.Int {
+	if p.noEIP1559 {
+		return nil
+	}
+	return b.baseFeePerGas
+}
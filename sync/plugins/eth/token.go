@@ -0,0 +1,229 @@
+package eth
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/sync/common"
+	"gitlab.com/sync/common/net/rpc"
+	"gitlab.com/sync/features"
+)
+
+const (
+	ethCall = "eth_call"
+
+	nameSelector     = "0x06fdde03" // name()
+	symbolSelector   = "0x95d89b41" // symbol()
+	decimalsSelector = "0x313ce567" // decimals()
+
+	maxTokenDecimals = 255 // decimals is an uint8
+)
+
+type callArgs struct {
+	To   string `json:"to"`
+	Data string `json:"data"`
+}
+
+// tokenResolver looks up token metadata with eth_call and keeps it, in a json file if token_cache is set.
+// A call that fails in the EVM, e.g. it reverts, leaves its field empty, so non-standard tokens are
+// resolved as far as they go. Any other failure, e.g. a rate limit or a timeout, leaves the token
+// unresolved and not kept, it is looked up again next time.
+type tokenResolver struct {
+	chain  string
+	client *rpc.Client
+	path   string
+
+	mu     sync.Mutex
+	tokens map[string]*features.TokenInfo // by lower case address
+}
+
+func newTokenResolver(chain string, client *rpc.Client, path string) (*tokenResolver, error) {
+	r := &tokenResolver{
+		chain:  chain,
+		client: client,
+		path:   path,
+		tokens: make(map[string]*features.TokenInfo),
+	}
+	if len(path) == 0 {
+		return r, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err = json.Unmarshal(data, &r.tokens); err != nil {
+		return nil, errors.Wrapf(err, "parse token cache %s", path)
+	}
+	return r, nil
+}
+
+// resolve returns the metadata of every address, calling the node for the ones not known yet.
+// Addresses that could not be looked up are nil, a lookup never fails the block.
+func (r *tokenResolver) resolve(addresses []string) map[string]*features.TokenInfo {
+	result := make(map[string]*features.TokenInfo, len(addresses))
+	var missing []string
+	r.mu.Lock()
+	for _, address := range addresses {
+		key := strings.ToLower(address)
+		if info, ok := r.tokens[key]; ok {
+			result[key] = info
+		} else if _, ok := result[key]; !ok {
+			result[key] = nil
+			missing = append(missing, address)
+		}
+	}
+	r.mu.Unlock()
+	if len(missing) == 0 {
+		return result
+	}
+
+	infos := r.call(missing)
+	if len(infos) == 0 {
+		return result
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, info := range infos {
+		key := strings.ToLower(info.Address)
+		r.tokens[key] = info
+		result[key] = info
+		logrus.
+			WithField("chain", r.chain).
+			WithField("token_address", info.Address).
+			WithField("symbol", info.Symbol).
+			WithField("decimals", info.Decimals).
+			Debug("token resolved")
+	}
+	if err := r.save(); err != nil {
+		logrus.
+			WithField("chain", r.chain).
+			Warnf("save token cache: %v", err)
+	}
+	return result
+}
+
+// call asks for name, symbol and decimals of every address in one batch. Addresses with a call
+// that failed outside the EVM are left out.
+func (r *tokenResolver) call(addresses []string) []*features.TokenInfo {
+	selectors := []string{nameSelector, symbolSelector, decimalsSelector}
+	results := make([]string, len(addresses)*len(selectors))
+	batch := make([]rpc.BatchElem, 0, len(results))
+	for _, address := range addresses {
+		for _, selector := range selectors {
+			batch = append(batch, rpc.BatchElem{
+				Method: ethCall,
+				Args:   []interface{}{&callArgs{To: address, Data: selector}, "latest"},
+				Result: &results[len(batch)],
+			})
+		}
+	}
+	// the batch only fails as a whole on transport errors, a failed call fails its element
+	if err := r.client.BatchSyncCall(batch); err != nil && !hasElemError(batch) {
+		logrus.
+			WithField("chain", r.chain).
+			WithField("tokens", len(addresses)).
+			Warnf("%s token metadata: %v", ethCall, err)
+		return nil
+	}
+
+	infos := make([]*features.TokenInfo, 0, len(addresses))
+	for i, address := range addresses {
+		elems := batch[i*len(selectors) : (i+1)*len(selectors)]
+		if err := lookupError(elems); err != nil {
+			logrus.
+				WithField("chain", r.chain).
+				WithField("token_address", address).
+				Warnf("%s token metadata: %v", ethCall, err)
+			continue
+		}
+		info := &features.TokenInfo{Address: checksumAddress(address), Decimals: features.UnknownDecimals}
+		if elems[0].Error == nil {
+			info.Name = decodeABIString(results[i*3])
+		}
+		if elems[1].Error == nil {
+			info.Symbol = decodeABIString(results[i*3+1])
+		}
+		if elems[2].Error == nil {
+			data, err := decodeABIData(results[i*3+2])
+			if err == nil {
+				if decimals, err := decodeABIUint(data, 0); err == nil && decimals.IsInt64() && decimals.Int64() <= maxTokenDecimals {
+					info.Decimals = int(decimals.Int64())
+				}
+			}
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// save writes the cache file, the caller holds the lock
+func (r *tokenResolver) save() error {
+	if len(r.path) == 0 {
+		return nil
+	}
+	data, err := json.MarshalIndent(r.tokens, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return common.WriteFileAtomic(r.path, data, 0644)
+}
+
+// lookupError the first error of elems that is not the EVM failing the call
+func lookupError(elems []rpc.BatchElem) error {
+	for _, elem := range elems {
+		if elem.Error != nil && !rpc.IsExecutionError(elem.Error) {
+			return elem.Error
+		}
+	}
+	return nil
+}
+
+func hasElemError(batch []rpc.BatchElem) bool {
+	for _, elem := range batch {
+		if elem.Error != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeABIString decodes a string return value. Tokens such as MKR return bytes32 instead,
+// which is read up to the first zero byte. Anything else decodes to an empty string.
+func decodeABIString(result string) string {
+	data, err := decodeABIData(result)
+	if err != nil {
+		return ""
+	}
+	var value []byte
+	switch {
+	case len(data) == abiWordSize:
+		value = data
+		if i := strings.IndexByte(string(data), 0); i >= 0 {
+			value = data[:i]
+		}
+	case len(data) >= 2*abiWordSize:
+		offset, err := decodeABIUint(data, 0)
+		if err != nil || !offset.IsInt64() || offset.Int64()%abiWordSize != 0 {
+			return ""
+		}
+		start := int(offset.Int64())
+		length, err := decodeABIUint(data, start/abiWordSize)
+		if err != nil || !length.IsInt64() || int64(start+abiWordSize)+length.Int64() > int64(len(data)) {
+			return ""
+		}
+		value = data[start+abiWordSize : start+abiWordSize+int(length.Int64())]
+	}
+	if !utf8.Valid(value) {
+		return ""
+	}
+	return strings.TrimRight(string(value), "\x00")
+}
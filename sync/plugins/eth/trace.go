@@ -0,0 +1,226 @@
+package eth
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/sync/common"
+	"gitlab.com/sync/features"
+)
+
+const (
+	debugTraceMode  = "debug"  // geth style debug_traceBlockByNumber with the callTracer
+	parityTraceMode = "parity" // erigon, nethermind and openethereum style trace_block
+
+	debugTraceBlock  = "debug_traceBlockByNumber"
+	parityTraceBlock = "trace_block"
+
+	callTracer = "callTracer"
+)
+
+// callFrame a call of the geth callTracer, calls are the frames it made
+type callFrame struct {
+	Type  string       `json:"type"`
+	From  string       `json:"from"`
+	To    string       `json:"to"`
+	Value string       `json:"value"`
+	Error string       `json:"error"`
+	Calls []*callFrame `json:"calls"`
+}
+
+type jsonTxTrace struct {
+	TxHash string     `json:"txHash"` // missing before geth 1.11, matched by position then
+	Result *callFrame `json:"result"`
+	Error  string     `json:"error"`
+}
+
+type parityTraceAction struct {
+	CallType      string `json:"callType"`
+	From          string `json:"from"`
+	To            string `json:"to"`
+	Value         string `json:"value"`
+	Address       string `json:"address"`       // suicide
+	RefundAddress string `json:"refundAddress"` // suicide
+	Balance       string `json:"balance"`       // suicide
+}
+
+type parityTraceResult struct {
+	Address string `json:"address"` // create
+}
+
+type jsonParityTrace struct {
+	Action          parityTraceAction  `json:"action"`
+	Result          *parityTraceResult `json:"result"`
+	Error           string             `json:"error"`
+	TraceAddress    []int              `json:"traceAddress"`
+	TransactionHash string             `json:"transactionHash"`
+	Type            string             `json:"type"`
+}
+
+// getInternalTransfers traces the block and returns, per transaction hash, the value moved by
+// calls made from contracts. The transaction's own value is not included.
+func (p *producer) getInternalTransfers(b *jsonBlock) (map[string][]*features.Transfer, error) {
+	switch p.cfg.Trace {
+	case "":
+		return nil, nil
+	case debugTraceMode:
+		return p.getDebugInternalTransfers(b)
+	case parityTraceMode:
+		return p.getParityInternalTransfers(b)
+	default:
+		return nil, fmt.Errorf("unsupported trace mode %s", p.cfg.Trace)
+	}
+}
+
+func (p *producer) getDebugInternalTransfers(b *jsonBlock) (map[string][]*features.Transfer, error) {
+	var traces []*jsonTxTrace
+	err := p.client.SyncCall(&traces, debugTraceBlock, fmt.Sprintf("0x%x", b.height), map[string]string{"tracer": callTracer})
+	if err != nil {
+		return nil, err
+	}
+	if len(traces) != len(b.Transactions) {
+		return nil, fmt.Errorf("the traces number: [%d] is not match related txes's: [%d] in %d",
+			len(traces), len(b.Transactions), b.height)
+	}
+	result := make(map[string][]*features.Transfer)
+	for i, trace := range traces {
+		txHash := trace.TxHash
+		if len(txHash) == 0 {
+			txHash = b.Transactions[i].Hash
+		}
+		if len(trace.Error) > 0 {
+			return nil, errors.Errorf("trace tx %s: %s", txHash, trace.Error)
+		}
+		if trace.Result == nil || len(trace.Result.Error) > 0 {
+			continue
+		}
+		var tfs []*features.Transfer
+		for j, call := range trace.Result.Calls {
+			tfs, err = collectCallTransfers(tfs, txHash, call, strconv.Itoa(j))
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(tfs) > 0 {
+			result[txHash] = tfs
+		}
+	}
+	return result, nil
+}
+
+// collectCallTransfers walks a call and the calls it made. A frame that failed is reverted
+// together with everything below it, delegate and static calls never move value.
+func collectCallTransfers(tfs []*features.Transfer, txHash string, frame *callFrame, path string) ([]*features.Transfer, error) {
+	if len(frame.Error) > 0 {
+		return tfs, nil
+	}
+	switch strings.ToUpper(frame.Type) {
+	case "CALL", "CREATE", "CREATE2", "SELFDESTRUCT":
+		tf, err := newInternalTransfer(txHash, frame.From, frame.To, frame.Value, path)
+		if err != nil {
+			return nil, err
+		}
+		if tf != nil {
+			tfs = append(tfs, tf)
+		}
+	}
+	for i, call := range frame.Calls {
+		var err error
+		tfs, err = collectCallTransfers(tfs, txHash, call, fmt.Sprintf("%s_%d", path, i))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tfs, nil
+}
+
+func (p *producer) getParityInternalTransfers(b *jsonBlock) (map[string][]*features.Transfer, error) {
+	var traces []*jsonParityTrace
+	err := p.client.SyncCall(&traces, parityTraceBlock, fmt.Sprintf("0x%x", b.height))
+	if err != nil {
+		return nil, err
+	}
+	// traces are in execution order, so a failed call is seen before the calls below it
+	failed := make(map[string]bool)
+	result := make(map[string][]*features.Transfer)
+	for _, trace := range traces {
+		if len(trace.TransactionHash) == 0 {
+			// block and uncle rewards
+			continue
+		}
+		path := traceAddressPath(trace.TraceAddress)
+		if len(trace.Error) > 0 {
+			failed[trace.TransactionHash+path] = true
+		}
+		if len(trace.TraceAddress) == 0 || isRevertedTrace(failed, trace) {
+			continue
+		}
+		var tf *features.Transfer
+		switch trace.Type {
+		case "call":
+			if trace.Action.CallType != "call" {
+				continue
+			}
+			tf, err = newInternalTransfer(trace.TransactionHash, trace.Action.From, trace.Action.To, trace.Action.Value, path)
+		case "create":
+			var to string
+			if trace.Result != nil {
+				to = trace.Result.Address
+			}
+			tf, err = newInternalTransfer(trace.TransactionHash, trace.Action.From, to, trace.Action.Value, path)
+		case "suicide":
+			tf, err = newInternalTransfer(trace.TransactionHash, trace.Action.Address, trace.Action.RefundAddress, trace.Action.Balance, path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if tf != nil {
+			result[trace.TransactionHash] = append(result[trace.TransactionHash], tf)
+		}
+	}
+	return result, nil
+}
+
+// isRevertedTrace reports whether the trace or a call above it failed
+func isRevertedTrace(failed map[string]bool, trace *jsonParityTrace) bool {
+	for i := 0; i <= len(trace.TraceAddress); i++ {
+		if failed[trace.TransactionHash+traceAddressPath(trace.TraceAddress[:i])] {
+			return true
+		}
+	}
+	return false
+}
+
+// traceAddressPath formats a trace address as the path used by the callTracer walk, e.g. 0_2
+func traceAddressPath(traceAddress []int) string {
+	path := make([]string, 0, len(traceAddress))
+	for _, i := range traceAddress {
+		path = append(path, strconv.Itoa(i))
+	}
+	return strings.Join(path, "_")
+}
+
+// newInternalTransfer returns nil if no value is moved
+func newInternalTransfer(txHash, from, to, value, path string) (*features.Transfer, error) {
+	if len(value) == 0 {
+		return nil, nil
+	}
+	amount, err := common.GetHexNumber(value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "tx %s call %s value", txHash, path)
+	}
+	if amount.Sign() == 0 {
+		return nil, nil
+	}
+	return &features.Transfer{
+		From:      checksumAddress(from),
+		To:        checksumAddress(to),
+		Amount:    amount,
+		Index:     "call_" + path,
+		Decimals:  coinDecimals,
+		TradeType: features.TradeTypeInternal,
+	}, nil
+}
@@ -0,0 +1,98 @@
+package eth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/sync/common/config"
+	"gitlab.com/sync/features"
+)
+
+// newFixtureServer answers every json-rpc call with testdata/<method>.json
+func newFixtureServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		data, err := os.ReadFile(filepath.Join("testdata", req.Method+".json"))
+		if err != nil {
+			resp["error"] = map[string]interface{}{"code": -32601, "message": "the method " + req.Method + " does not exist/is not available"}
+		} else {
+			resp["result"] = json.RawMessage(data)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func testTraceBlock() *jsonBlock {
+	return &jsonBlock{
+		height: 16,
+		Transactions: []*jsonTransaction{
+			{Hash: "0x1111111111111111111111111111111111111111111111111111111111111111"},
+			{Hash: "0x2222222222222222222222222222222222222222222222222222222222222222"},
+			{Hash: "0x3333333333333333333333333333333333333333333333333333333333333333"},
+		},
+	}
+}
+
+func testInternalTransfers(t *testing.T, mode string) {
+	server := newFixtureServer(t)
+	defer server.Close()
+
+	p, err := NewProducer("eth", &config.Producer{URL: server.URL, Trace: mode, ChainID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := testTraceBlock()
+	result, err := p.(*producer).getInternalTransfers(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txHash := b.Transactions[0].Hash
+	expected := []struct{ from, to, amount string }{
+		{from: "0x00000000000000000000000000000000000000a0", to: "0x00000000000000000000000000000000000000A1", amount: "1000000000000000000"},
+		{from: "0x00000000000000000000000000000000000000a0", to: "0x00000000000000000000000000000000000000C0", amount: "5"},
+		{from: "0x00000000000000000000000000000000000000C0", to: "0x00000000000000000000000000000000000000a4", amount: "5"},
+	}
+	if len(result) != 1 {
+		t.Fatalf("expected internal transfers for 1 tx, got %d", len(result))
+	}
+	tfs := result[txHash]
+	if len(tfs) != len(expected) {
+		t.Fatalf("expected %d internal transfers, got %d", len(expected), len(tfs))
+	}
+	indexes := make(map[string]bool)
+	for i, tf := range tfs {
+		if tf.TradeType != features.TradeTypeInternal {
+			t.Errorf("transfer %d: unexpected trade type %s", i, tf.TradeType)
+		}
+		if tf.From != expected[i].from || tf.To != expected[i].to || tf.Amount.String() != expected[i].amount {
+			t.Errorf("transfer %d: got %s -> %s %s, want %s -> %s %s", i,
+				tf.From, tf.To, tf.Amount, expected[i].from, expected[i].to, expected[i].amount)
+		}
+		if indexes[tf.Index] {
+			t.Errorf("transfer %d: duplicated index %s", i, tf.Index)
+		}
+		indexes[tf.Index] = true
+	}
+}
+
+func TestDebugInternalTransfers(t *testing.T) {
+	testInternalTransfers(t, debugTraceMode)
+}
+
+func TestParityInternalTransfers(t *testing.T) {
+	testInternalTransfers(t, parityTraceMode)
+}
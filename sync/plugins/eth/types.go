@@ -6,14 +6,32 @@ import (
 
 	"github.com/pkg/errors"
 	"gitlab.com/sync/common"
+	"gitlab.com/sync/features"
 )
 
 const (
 	getBlockNumber = "eth_blockNumber"
 	getBlock       = "eth_getBlockByNumber"
 	getReceipt     = "eth_getTransactionReceipt"
+	getReceipts    = "eth_getBlockReceipts"
+	getLogs        = "eth_getLogs"
+	getChainID     = "eth_chainId"
 
-	transferEventHash = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	blockReceiptsMethod = "block" // eth_getBlockReceipts
+	batchReceiptsMethod = "batch" // batched eth_getTransactionReceipt
+
+	defaultBatchSize = 100
+
+	coinDecimals = 18
+
+	depositTxType = 0x7e // OP-stack deposit, sent by the bridge and paying no gas
+
+	finalizedBlockTag = "finalized"
+
+	transferEventHash       = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef" // ERC-20 and ERC-721 Transfer
+	transferSingleEventHash = "0xc3d58168c5ae7397731d063d5bbf3d657854427343f4c083240f7aacaa2d0f62" // ERC-1155 TransferSingle
+	transferBatchEventHash  = "0x4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb" // ERC-1155 TransferBatch
+	logTransferEventHash    = "0xe6497e3ee548a3372136af2fcb0696db31fc6cf20260707645068bd3fe97f3c4" // Polygon MRC20 LogTransfer
 )
 
 type jsonBlock struct {
@@ -32,6 +50,7 @@ type jsonBlock struct {
 
 	receiptsReady bool
 	receiptsMap   map[string]*jsonTransactionReceipt
+	internalTfs   map[string][]*features.Transfer
 
 	height, time     int
 	hash, parentHash string
@@ -56,6 +75,14 @@ func (b *jsonBlock) GetBlockTime() int {
 	return b.time
 }
 
+func (b *jsonBlock) GetTxHashes() []string {
+	result := make([]string, 0, len(b.Transactions))
+	for _, tx := range b.Transactions {
+		result = append(result, tx.Hash)
+	}
+	return result
+}
+
 type jsonTransactionReceipt struct {
 	TransactionHash   string        `json:"transactionHash"`
 	TransactionIndex  string        `json:"transactionIndex"`
@@ -64,13 +91,14 @@ type jsonTransactionReceipt struct {
 	CumulativeGasUsed string        `json:"cumulativeGasUsed"`
 	EffectiveGasPrice string        `json:"effectiveGasPrice"` //EIP-1559 price = min(maxPriorityFeePerGas, maxFeePerGas - baseFee) + baseFee
 	GasUsed           string        `json:"gasUsed"`
+	ContractAddress   string        `json:"contractAddress"`
 	Status            string        `json:"status"`
 	Logs              []receiptLogs `json:"logs"`
 
 	// opteth
 	// L2 execution fee = tx.gasPrice * l2GasUsed
 	// 总手续费 = L2 execution fee  + L1 security fee
-	L1Fee string `json:"l1Fee"`
+	L1Fee string `json:"l1Fee"` // missing on deposits and before the first L1 fee update
 }
 
 type receiptLogs struct {
@@ -83,6 +111,8 @@ type receiptLogs struct {
 	Data             string   `json:"data"`
 	Topics           []string `json:"topics"`
 	Type             string   `json:"type"`
+	Removed          bool     `json:"removed"`
+	BlockTimestamp   string   `json:"blockTimestamp"` // eth_getLogs of recent nodes only
 }
 
 func (b *jsonBlock) convert() error {
@@ -98,6 +128,12 @@ func (b *jsonBlock) convert() error {
 	b.height = int(value.Int64())
 	b.hash = b.Hash
 	b.parentHash = b.ParentHash
+	if len(b.BaseFeePerGas) > 0 {
+		b.baseFeePerGas, err = common.GetHexNumber(b.BaseFeePerGas)
+		if err != nil {
+			return err
+		}
+	}
 	b.uncles = make([]string, 0, len(b.Uncles))
 	for _, item := range b.Uncles {
 		b.uncles = append(b.uncles, item)
@@ -123,37 +159,46 @@ type jsonTransaction struct {
 	Action               int    `json:"action"`     //for eminer transfer action
 	SubAddress           string `json:"subAddress"` //for eminer transfer subAddress
 	TxType               string `json:"type"`
+	Mint                 string `json:"mint"`       //OP-stack deposit: coins minted on L2
+	SourceHash           string `json:"sourceHash"` //OP-stack deposit: identifies the L1 deposit
 
 	hash, from, to, totag                             string
-	tfs                                               []*transfer
-	tokenTfs                                          []*transfer
-	gas, gasPrice, gasUsed, amount, fee               *big.Int
+	tfs                                               []*features.Transfer
+	tokenTfs                                          []*features.Transfer
+	gas, gasPrice, gasUsed, amount, fee, mint         *big.Int
+	evmFee                                            *features.EVMFee
 	nonce, status, receiptStatus, blockHeight, txType uint64
+	pending                                           bool // in the pool, without receipt
 }
 
 func (t *jsonTransaction) GetHash() string {
 	return t.Hash
 }
 
-func (t *jsonTransaction) TokenAddress() string {
-	if len(t.tokenTfs) == 0 {
-		return ""
-	}
-	return t.tokenTfs[0].assetChainName
+// Transfers the native transfer first, then internal transfers, then token transfers by log index
+func (t *jsonTransaction) Transfers() []*features.Transfer {
+	result := make([]*features.Transfer, 0, len(t.tfs)+len(t.tokenTfs))
+	result = append(result, t.tfs...)
+	return append(result, t.tokenTfs...)
 }
-func (t *jsonTransaction) FromAddress() string {
-	return t.From
+
+func (t *jsonTransaction) Status() features.TxStatus {
+	if t.pending {
+		return features.TxStatusPending
+	}
+	if t.receiptStatusSuccess() {
+		return features.TxStatusSuccess
+	}
+	return features.TxStatusFailed
 }
 
-func (t *jsonTransaction) ToAddress() string {
-	return t.To
+// Fee in wei, gasUsed * effectiveGasPrice. Paid even when the transaction fails.
+func (t *jsonTransaction) Fee() *big.Int {
+	return t.fee
 }
 
-func (t *jsonTransaction) Amount() string {
-	if len(t.tokenTfs) == 0 {
-		return ""
-	}
-	return t.tokenTfs[0].amount
+func (t *jsonTransaction) EVMFee() *features.EVMFee {
+	return t.evmFee
 }
 
 func (t *jsonTransaction) receiptStatusSuccess() bool {
@@ -163,49 +208,139 @@ func (t *jsonTransaction) receiptStatusSuccess() bool {
 func (t *jsonTransaction) convert() error {
 	t.hash = t.Hash
 	var err error
-	t.txType, err = strconv.ParseUint(t.TxType, 0, 64)
+	t.txType, err = parseOptionalUint(t.TxType)
+	if err != nil {
+		return err
+	}
+	t.from = checksumAddress(t.From)
+	t.to = checksumAddress(t.To)
+	t.amount, err = common.GetHexNumber(t.Value)
+	if err != nil {
+		return err
+	}
+	t.gasPrice, err = parseOptionalHex(t.GasPrice)
+	if err != nil {
+		return err
+	}
+	t.gas, err = common.GetHexNumber(t.Gas)
+	if err != nil {
+		return err
+	}
+	t.mint, err = parseOptionalHex(t.Mint)
 	if err != nil {
 		return err
 	}
-	t.from = t.From
-	t.to = t.To
 
-	t.tfs = make([]*transfer, 0, 1)
-	t.tokenTfs = make([]*transfer, 0, 1)
-	t.nonce, err = strconv.ParseUint(t.Nonce, 0, 64)
-	return errors.WithStack(err)
+	t.tfs = make([]*features.Transfer, 0, 1)
+	t.tokenTfs = make([]*features.Transfer, 0, 1)
+	t.nonce, err = parseOptionalUint(t.Nonce)
+	return err
 }
 
-func (t *jsonTransaction) combineReceipt(r *jsonTransactionReceipt) error {
+// parseOptionalHex parses a quantity that some transaction types leave out, e.g. the gas price
+// of deposits, as zero when missing
+func parseOptionalHex(value string) (*big.Int, error) {
+	if len(value) == 0 {
+		return new(big.Int), nil
+	}
+	return common.GetHexNumber(value)
+}
+
+func parseOptionalUint(value string) (uint64, error) {
+	if len(value) == 0 {
+		return 0, nil
+	}
+	result, err := strconv.ParseUint(value, 0, 64)
+	return result, errors.WithStack(err)
+}
+
+// combineReceipt takes status and gas from the receipt, baseFee is the block's, nil before EIP-1559
+func (t *jsonTransaction) combineReceipt(r *jsonTransactionReceipt, baseFee *big.Int, prof *profile) error {
 	var err error
 	t.receiptStatus, err = strconv.ParseUint(r.Status, 0, 64)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 	t.status = t.receiptStatus
+	if len(t.to) == 0 {
+		// contract creation
+		t.to = checksumAddress(r.ContractAddress)
+	}
+	t.gasUsed, err = common.GetHexNumber(r.GasUsed)
+	if err != nil {
+		return err
+	}
+	if prof.opStack && t.txType == depositTxType {
+		// gas of deposits is bought on L1
+		t.fee = new(big.Int)
+		t.evmFee = &features.EVMFee{
+			GasLimit:          t.gas,
+			GasUsed:           t.gasUsed,
+			EffectiveGasPrice: new(big.Int),
+			BaseFee:           baseFee,
+		}
+		return nil
+	}
+	price := t.gasPrice
+	if len(r.EffectiveGasPrice) > 0 {
+		price, err = common.GetHexNumber(r.EffectiveGasPrice)
+		if err != nil {
+			return err
+		}
+	}
+	t.fee = new(big.Int).Mul(t.gasUsed, price)
+	t.evmFee = &features.EVMFee{
+		GasLimit:          t.gas,
+		GasUsed:           t.gasUsed,
+		EffectiveGasPrice: price,
+		BaseFee:           baseFee,
+	}
+	// the base fee of OP-stack rollups goes to a fee vault, nothing is burnt
+	if !prof.opStack {
+		t.evmFee.Burnt = new(big.Int)
+		t.evmFee.Tip = new(big.Int).Set(t.fee)
+		if baseFee != nil {
+			t.evmFee.Burnt.Mul(t.gasUsed, baseFee)
+			t.evmFee.Tip.Sub(t.fee, t.evmFee.Burnt)
+		}
+	}
+	// total fee = L2 execution fee + L1 data fee
+	if prof.opStack && len(r.L1Fee) > 0 {
+		t.evmFee.L1Fee, err = common.GetHexNumber(r.L1Fee)
+		if err != nil {
+			return err
+		}
+		t.fee.Add(t.fee, t.evmFee.L1Fee)
+	}
 	return nil
 }
 
-type transfer struct {
-	from, to, totag string
-	amount          string
-	index           string
-	txHash          string
-	fee             string
-	tradeType       tradeType
-	assetChainName  string
+// getNativeTransfer the coins moved by the transaction: the mint of a deposit, which stands
+// even if the deposit fails, and the value of a successful transaction
+func (t *jsonTransaction) getNativeTransfer() []*features.Transfer {
+	var result []*features.Transfer
+	if t.mint != nil && t.mint.Sign() > 0 {
+		result = append(result, &features.Transfer{
+			Index:     "mint",
+			To:        t.from,
+			Amount:    t.mint,
+			Decimals:  coinDecimals,
+			TradeType: features.TradeTypeDeposit,
+		})
+	}
+	if t.receiptStatusSuccess() && t.amount.Sign() > 0 {
+		result = append(result, &features.Transfer{
+			From:      t.from,
+			To:        t.to,
+			Amount:    t.amount,
+			Decimals:  coinDecimals,
+			TradeType: features.TradeTypeTransfer,
+		})
+	}
+	return result
 }
 
-type tradeType string
-
 const (
-	coinbaseTradeType          tradeType = "coinbase"
-	transferTradeType          tradeType = "transfer"
-	contractTradeType          tradeType = "contract"
-	nftTransferTradeType       tradeType = "nft_transfer"
-	nftTransferSingleTradeType tradeType = "nft_transfer_single"
-	nftTransferBatchTradeType  tradeType = "nft_transfer_batch"
-	mainCoinContractTradeType  tradeType = "main_coin_contract"
-	celoGoldTokenProxy                   = "471ece3750da237f93b8e339c536989b8978a438"
-	maticMRC20                           = "0000000000000000000000000000000000001010"
+	celoGoldTokenProxy = "471ece3750da237f93b8e339c536989b8978a438"
+	maticMRC20         = "0000000000000000000000000000000000001010"
 )
@@ -7,6 +7,7 @@ import (
 
 	"github.com/pkg/errors"
 
+	"gitlab.com/sync/common/checkpoint"
 	"gitlab.com/sync/common/config"
 	"gitlab.com/sync/features"
 	"gitlab.com/sync/plugins/eth"
@@ -17,34 +18,65 @@ type Plugin struct {
 	Consumer features.Consumer
 }
 
-type newProducer func(cfg *config.Producer) (features.Producer, error)
-type newConsumer func(cfg *config.Consumer) (features.Consumer, error)
+type newProducer func(chain string, cfg *config.Producer) (features.Producer, error)
+type newConsumer func(chain string, cfg *config.Consumer, store checkpoint.Store) (features.Consumer, error)
 
+// plugins by producer type, a chain without type uses its own name
 var (
 	supportedProducer = map[string]newProducer{
-		"btc": btc.NewProducer,
-		"eth": eth.NewProducer,
+		"utxo":     btc.NewProducer,
+		"btc":      btc.NewProducer,
+		"ltc":      btc.NewProducer,
+		"doge":     btc.NewProducer,
+		"bch":      btc.NewProducer,
+		"eth":      eth.NewProducer,
+		"evm":      eth.NewProducer,
+		"optimism": eth.NewProducer,
+		"base":     eth.NewProducer,
+		"polygon":  eth.NewProducer,
+		"celo":     eth.NewProducer,
 	}
 	supportedConsumer = map[string]newConsumer{
-		"btc": btc.NewConsumer,
-		"eth": eth.NewConsumer,
+		"utxo":     btc.NewConsumer,
+		"btc":      btc.NewConsumer,
+		"ltc":      btc.NewConsumer,
+		"doge":     btc.NewConsumer,
+		"bch":      btc.NewConsumer,
+		"eth":      eth.NewConsumer,
+		"evm":      eth.NewConsumer,
+		"optimism": eth.NewConsumer,
+		"base":     eth.NewConsumer,
+		"polygon":  eth.NewConsumer,
+		"celo":     eth.NewConsumer,
 	}
 )
 
-func Loader(chains []string, cfg *config.Config) (map[string]*Plugin, error) {
+func Loader(chains []string, cfg *config.Config, store checkpoint.Store) (map[string]*Plugin, error) {
 	result := make(map[string]*Plugin)
 	for _, v := range chains {
+		producerCfg, ok := cfg.Producers[v]
+		if !ok {
+			return nil, fmt.Errorf("chain %s has no producer section", v)
+		}
+		consumerCfg, ok := cfg.Consumers[v]
+		if !ok {
+			return nil, fmt.Errorf("chain %s has no consumer section", v)
+		}
+		pluginType := producerCfg.Type
+		if len(pluginType) == 0 {
+			pluginType = v
+		}
 		p := &Plugin{}
-		if f, ok := supportedProducer[v]; !ok {
-			return nil, fmt.Errorf("unsupported chain %s", v)
-		} else if producer, err := f(cfg.Producers[v]); err != nil {
+		if f, ok := supportedProducer[pluginType]; !ok {
+			return nil, fmt.Errorf("unsupported chain %s of type %s", v, pluginType)
+		} else if producer, err := f(v, producerCfg); err != nil {
 			return nil, errors.Wrapf(err, "init chain %s", v)
 		} else {
 			p.Producer = producer
 		}
-		if f, ok := supportedConsumer[v]; !ok {
-			return nil, fmt.Errorf("unsupported chain %s", v)
-		} else if consumer, err := f(cfg.Consumers[v]); err != nil {
+		if f, ok := supportedConsumer[pluginType]; !ok {
+			return nil, fmt.Errorf("unsupported chain %s of type %s", v, pluginType)
+		} else if consumer, err := f(v, consumerCfg, store); err != nil {
 			return nil, errors.Wrapf(err, "init chain %s", v)
 		} else {
 			p.Consumer = consumer